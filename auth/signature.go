@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request 参与签名的请求内容
+type Request struct {
+	Method    string
+	Path      string
+	Query     url.Values
+	Body      []byte
+	Timestamp int64
+}
+
+// Sign 使用 SecretKey 对请求方法、路径、规范化的查询参数、请求体的 SHA-256 和时间戳签名，
+// 返回 base64 编码的 HMAC-SHA256
+func Sign(secretKey string, req Request) string {
+	body := sha256.Sum256(req.Body)
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(strings.Join([]string{
+		req.Method,
+		req.Path,
+		CanonicalQuery(req.Query),
+		hex.EncodeToString(body[:]),
+		strconv.FormatInt(req.Timestamp, 10),
+	}, "\n")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名，并要求时间戳与当前时间的偏差不超过 maxSkew
+func Verify(secretKey string, req Request, signature string, maxSkew time.Duration) bool {
+	skew := time.Since(time.Unix(req.Timestamp, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secretKey, req)), []byte(signature))
+}
+
+// CanonicalQuery 按参数名和值排序后编码查询参数，与参数在 URL 中的顺序无关
+func CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,344 @@
+package event
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRecentErrors 保留的最近错误数
+const maxRecentErrors = 100
+
+// ErrTopicPaused 主题已被暂停，拒绝发布
+var ErrTopicPaused = errors.New("event: topic paused")
+
+// ConnectionInfo WebSocket 或 SSE 连接的状态
+type ConnectionInfo struct {
+	Id          string    `json:"id"`
+	Remote      string    `json:"remote"`
+	Transport   string    `json:"transport"` // websocket 或 sse
+	Protocol    bool      `json:"protocol"`  // 是否使用 ProtocolV1 控制协议
+	Principal   string    `json:"principal,omitempty"`
+	Topics      []string  `json:"topics"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	BytesSent   int64     `json:"bytesSent"`
+	Queued      int       `json:"queued"` // 出站队列中待发送的事件数
+}
+
+// TopicStats 主题的发布和投递计数
+type TopicStats struct {
+	Topic     string `json:"topic"`
+	Published int64  `json:"published"`
+	Delivered int64  `json:"delivered"` // 成功放入订阅者队列或推送成功的次数
+	Dropped   int64  `json:"dropped"`
+	Paused    bool   `json:"paused"`
+}
+
+// BrokerStats 租户在 Broker 中的整体状态。Consume 通道为进程级资源，其状态只对默认租户返回
+type BrokerStats struct {
+	Clients          int            `json:"clients"`
+	Handlers         map[string]int `json:"handlers"` // 主题模式到可接收该租户事件的进程内订阅者数
+	ConsumerQueued   int            `json:"consumerQueued,omitempty"`
+	ConsumerCapacity int            `json:"consumerCapacity,omitempty"`
+	ConsumerDropped  int64          `json:"consumerDropped,omitempty"` // Consume 通道未及时读取而丢弃的事件数，不计入 Dropped
+	Dropped          int64          `json:"dropped"`                   // 租户事件因订阅者队列已满或推送失败被丢弃的次数
+}
+
+// ErrorRecord 发布或推送失败的记录
+type ErrorRecord struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"` // publish 或 deliver
+	Tenant  string    `json:"tenantId,omitempty"`
+	Topic   string    `json:"topic"`
+	EventId string    `json:"eventId,omitempty"`
+	Message string    `json:"message"`
+}
+
+// topicKey 租户内的主题
+type topicKey struct {
+	tenant string
+	topic  string
+}
+
+// topicCounters 主题计数，由 b.mu 保护 map，计数本身可并发更新
+type topicCounters struct {
+	published atomic.Int64
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// publish 记录一次发布，c 为 nil 时不计数
+func (c *topicCounters) publish() {
+	if c != nil {
+		c.published.Add(1)
+	}
+}
+
+// count 按入队结果计数，c 为 nil 时不计数
+func (c *topicCounters) count(result pushResult) {
+	if c == nil {
+		return
+	}
+	if result == pushed {
+		c.delivered.Add(1)
+	} else {
+		c.dropped.Add(1)
+	}
+}
+
+// errorLog 最近错误的环形缓冲
+type errorLog struct {
+	mu      sync.Mutex
+	records []ErrorRecord
+	next    int
+}
+
+func (l *errorLog) add(record ErrorRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.records) < maxRecentErrors {
+		l.records = append(l.records, record)
+		return
+	}
+	l.records[l.next] = record
+	l.next = (l.next + 1) % maxRecentErrors
+}
+
+// list 按时间先后返回记录
+func (l *errorLog) list() []ErrorRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]ErrorRecord, 0, len(l.records))
+	records = append(records, l.records[l.next:]...)
+	return append(records, l.records[:l.next]...)
+}
+
+// counters 返回事件所属租户主题的计数，调用方需持有 b.mu。
+// Request 的回复主题每个请求各不相同，不计数，避免计数表无限增长
+func (b *Broker) counters(event *Event) *topicCounters {
+	if strings.HasPrefix(event.Topic, InboxTopicPrefix) {
+		return nil
+	}
+	key := topicKey{tenant: tenantOf(event), topic: event.Topic}
+	c, ok := b.stats[key]
+	if !ok {
+		c = &topicCounters{}
+		b.stats[key] = c
+	}
+	return c
+}
+
+// countDelivery 记录一次 webhook 推送结果
+func (b *Broker) countDelivery(event *Event, result pushResult) {
+	b.mu.Lock()
+	counters := b.counters(event)
+	b.mu.Unlock()
+	counters.count(result)
+}
+
+// recordError 记录发布或推送失败
+func (b *Broker) recordError(op string, event *Event, err error) {
+	b.errors.add(ErrorRecord{
+		Time:    time.Now(),
+		Op:      op,
+		Tenant:  tenantOf(event),
+		Topic:   event.Topic,
+		EventId: event.Id,
+		Message: err.Error(),
+	})
+}
+
+// isPaused 判断事件的主题是否被暂停
+func (b *Broker) isPaused(event *Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tenant := tenantOf(event)
+	for key := range b.paused {
+		if key.tenant == tenant && MatchTopic(key.topic, event.Topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// PauseTopic 暂停租户中匹配 topic 的主题，暂停期间发布返回 ErrTopicPaused
+func (b *Broker) PauseTopic(tenant, topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused[topicKey{tenant: tenant, topic: topic}] = struct{}{}
+}
+
+// ResumeTopic 恢复通过 PauseTopic 暂停的主题，返回该主题此前是否被暂停
+func (b *Broker) ResumeTopic(tenant, topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := topicKey{tenant: tenant, topic: topic}
+	_, ok := b.paused[key]
+	delete(b.paused, key)
+	return ok
+}
+
+// Connections 返回租户当前的 WebSocket 和 SSE 连接
+func (b *Broker) Connections(tenant string) []ConnectionInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	conns := make([]ConnectionInfo, 0, len(b.clients))
+	for c := range b.clients {
+		if c.tenant != tenant {
+			continue
+		}
+		info := ConnectionInfo{
+			Id:          c.id,
+			Remote:      c.remote,
+			Transport:   "sse",
+			Protocol:    c.protocol,
+			Topics:      make([]string, 0, len(c.topics)),
+			ConnectedAt: c.connectedAt,
+			BytesSent:   c.bytesSent.Load(),
+			Queued:      len(c.out.ch),
+		}
+		if c.conn != nil {
+			info.Transport = "websocket"
+		}
+		if c.principal != nil {
+			info.Principal = c.principal.Id
+		}
+		for topic := range c.topics {
+			info.Topics = append(info.Topics, topic)
+		}
+		sort.Strings(info.Topics)
+		conns = append(conns, info)
+	}
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ConnectedAt.Before(conns[j].ConnectedAt) })
+	return conns
+}
+
+// Disconnect 强制断开租户中的连接，返回连接是否存在
+func (b *Broker) Disconnect(tenant, id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		if c.tenant == tenant && c.id == id {
+			b.detachClient(c)
+			c.close()
+			return true
+		}
+	}
+	return false
+}
+
+// TopicStats 返回租户各主题的发布和投递计数
+func (b *Broker) TopicStats(tenant string) []TopicStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := make([]TopicStats, 0, len(b.stats))
+	for key, c := range b.stats {
+		if key.tenant != tenant {
+			continue
+		}
+		stats = append(stats, TopicStats{
+			Topic:     key.topic,
+			Published: c.published.Load(),
+			Delivered: c.delivered.Load(),
+			Dropped:   c.dropped.Load(),
+		})
+	}
+	for i := range stats {
+		for key := range b.paused {
+			if key.tenant == tenant && MatchTopic(key.topic, stats[i].Topic) {
+				stats[i].Paused = true
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Topic < stats[j].Topic })
+	return stats
+}
+
+// Stats 返回租户的整体状态
+func (b *Broker) Stats(tenant string) BrokerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BrokerStats{Handlers: make(map[string]int)}
+	for c := range b.clients {
+		if c.tenant == tenant {
+			stats.Clients++
+		}
+	}
+	for topic, handlers := range b.topics {
+		for _, h := range handlers {
+			if !h.scoped || h.tenant == tenant {
+				stats.Handlers[topic]++
+			}
+		}
+	}
+	for key, c := range b.stats {
+		if key.tenant == tenant {
+			stats.Dropped += c.dropped.Load()
+		}
+	}
+	if tenant == "" {
+		stats.ConsumerQueued = len(b.eventsCh.ch)
+		stats.ConsumerCapacity = cap(b.eventsCh.ch)
+		stats.ConsumerDropped = b.eventsCh.dropped.Load()
+	}
+	return stats
+}
+
+// RecentErrors 返回最近的发布和推送错误，按时间先后排列
+func (b *Broker) RecentErrors() []ErrorRecord {
+	return b.errors.list()
+}
+
+func (b *Broker) handleGetConnections(c *gin.Context) {
+	c.JSON(http.StatusOK, b.Connections(requestTenant(c)))
+}
+
+func (b *Broker) handleDisconnect(c *gin.Context) {
+	if !b.Disconnect(requestTenant(c), c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (b *Broker) handleGetTopicStats(c *gin.Context) {
+	c.JSON(http.StatusOK, b.TopicStats(requestTenant(c)))
+}
+
+func (b *Broker) handlePauseTopic(c *gin.Context) {
+	b.PauseTopic(requestTenant(c), c.Param("topic"))
+	c.JSON(http.StatusOK, gin.H{"message": "Topic paused", "topic": c.Param("topic")})
+}
+
+func (b *Broker) handleResumeTopic(c *gin.Context) {
+	if !b.ResumeTopic(requestTenant(c), c.Param("topic")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not paused"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Topic resumed", "topic": c.Param("topic")})
+}
+
+func (b *Broker) handleGetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, b.Stats(requestTenant(c)))
+}
+
+// handleGetErrors 返回请求所属租户最近的错误
+func (b *Broker) handleGetErrors(c *gin.Context) {
+	tenant := requestTenant(c)
+	records := make([]ErrorRecord, 0)
+	for _, record := range b.RecentErrors() {
+		if record.Tenant == tenant {
+			records = append(records, record)
+		}
+	}
+	c.JSON(http.StatusOK, records)
+}
@@ -0,0 +1,72 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Admin(t *testing.T) {
+	b := NewBroker()
+	b.Subscribe("orders.#", func(*Event) {})
+
+	b.mu.Lock()
+	require.True(t, b.tenants.acquireConn(""))
+	cli := b.addClient(nil, "10.0.0.1", nil, "", "orders.*")
+	b.mu.Unlock()
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders.created"}))
+
+	conns := b.Connections("")
+	require.Len(t, conns, 1)
+	require.Equal(t, "sse", conns[0].Transport)
+	require.Equal(t, []string{"orders.*"}, conns[0].Topics)
+	require.Equal(t, 1, conns[0].Queued)
+	require.Empty(t, b.Connections("t1"))
+
+	stats := b.TopicStats("")
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(1), stats[0].Published)
+	require.Equal(t, int64(2), stats[0].Delivered)
+	require.Equal(t, 1, b.Stats("").Handlers["orders.#"])
+	require.Equal(t, 1, b.Stats("").Clients)
+	require.Equal(t, DefaultQueueOptions.Size, b.Stats("").ConsumerCapacity)
+
+	// 其他租户看不到默认租户的连接和专属订阅者，也看不到进程级的 Consume 通道
+	b.SubscribeTenant("", "orders.#", func(*Event) {})
+	stats1 := b.Stats("t1")
+	require.Zero(t, stats1.Clients)
+	require.Equal(t, 1, stats1.Handlers["orders.#"])
+	require.Zero(t, stats1.ConsumerCapacity)
+	require.Equal(t, 2, b.Stats("").Handlers["orders.#"])
+
+	// Request 的回复主题不计数
+	require.NoError(t, b.Publish(&Event{Topic: InboxTopicPrefix + "1"}))
+	require.Len(t, b.TopicStats(""), 1)
+
+	b.PauseTopic("", "orders.*")
+	require.ErrorIs(t, b.Publish(&Event{Topic: "orders.created"}), ErrTopicPaused)
+	require.NoError(t, b.Publish(&Event{Topic: "orders.created", Payload: &Payload{TenantId: "t1"}}))
+	require.True(t, b.TopicStats("")[0].Paused)
+	require.True(t, b.ResumeTopic("", "orders.*"))
+	require.False(t, b.ResumeTopic("", "orders.*"))
+
+	require.False(t, b.Disconnect("t1", conns[0].Id))
+	require.True(t, b.Disconnect("", conns[0].Id))
+	require.Empty(t, b.Connections(""))
+	<-cli.out.ch
+	_, ok := <-cli.out.ch
+	require.False(t, ok)
+}
+
+func TestErrorLog(t *testing.T) {
+	var log errorLog
+	for i := 0; i < maxRecentErrors+5; i++ {
+		log.add(ErrorRecord{Time: time.Unix(int64(i), 0), Message: "failed"})
+	}
+	records := log.list()
+	require.Len(t, records, maxRecentErrors)
+	require.Equal(t, int64(5), records[0].Time.Unix())
+	require.Equal(t, int64(maxRecentErrors+4), records[len(records)-1].Time.Unix())
+}
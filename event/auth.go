@@ -0,0 +1,278 @@
+package event
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagraler/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey 认证结果在 gin.Context 中的键
+const principalKey = "event.principal"
+
+// AK/SK 签名认证使用的请求头
+const (
+	AccessKeyHeader = "X-Access-Key"
+	TimestampHeader = "X-Timestamp"
+	SignHeader      = "X-Signature"
+)
+
+// DefaultMaxSkew AK/SK 签名时间戳允许的最大偏差
+const DefaultMaxSkew = 5 * time.Minute
+
+// ErrUnauthenticated 请求未携带凭证或凭证无效
+var ErrUnauthenticated = errors.New("event: unauthenticated")
+
+// Principal 认证通过的调用方
+type Principal struct {
+	Id       string `json:"id"`
+	TenantId string `json:"tenantId,omitempty"`
+	// CrossTenant 为 true 时不属于任何租户的调用方可通过 TenantHeader 或 tenant 参数访问任意租户，
+	// 仅授予运维等受信任的调用方
+	CrossTenant bool `json:"crossTenant,omitempty"`
+}
+
+// Authenticator 认证 HTTP 请求（包括 WebSocket 升级请求），认证失败时返回 error
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc 函数形式的 Authenticator
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+// Authenticate 实现 Authenticator
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// BearerAuthenticator 校验 Authorization: Bearer <token>。
+// 浏览器的 WebSocket 和 EventSource 无法设置请求头，因此也接受 access_token 查询参数
+type BearerAuthenticator struct {
+	// Lookup 根据 token 返回调用方，token 无效时返回 error
+	Lookup func(token string) (*Principal, error)
+}
+
+// Authenticate 实现 Authenticator
+func (a *BearerAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.URL.Query().Get("access_token")
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, credentials, ok := strings.Cut(h, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, ErrUnauthenticated
+		}
+		token = credentials
+	}
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	return a.Lookup(token)
+}
+
+// maxSignedBodySize AK/SK 认证读取的请求体上限，请求体参与签名
+const maxSignedBodySize = 10 << 20
+
+// AKSKAuthenticator 校验 auth.Sign 生成的 AK/SK 签名，签名内容为请求方法、路径、查询参数、
+// 请求体和 X-Timestamp，签名被截获后无法用于其他参数或请求体
+type AKSKAuthenticator struct {
+	// Lookup 根据 AccessKey 返回 SecretKey 和调用方
+	Lookup func(accessKey string) (secretKey string, principal *Principal, err error)
+	// MaxSkew 时间戳允许的最大偏差，为 0 时使用 DefaultMaxSkew
+	MaxSkew time.Duration
+}
+
+// Authenticate 实现 Authenticator
+func (a *AKSKAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	accessKey := r.Header.Get(AccessKeyHeader)
+	signature := r.Header.Get(SignHeader)
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if accessKey == "" || signature == "" || err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	secretKey, principal, err := a.Lookup(accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSkew := a.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	req := auth.Request{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.Query(),
+		Body:      body,
+		Timestamp: timestamp,
+	}
+	if !auth.Verify(secretKey, req, signature, maxSkew) {
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+// readBody 读取请求体并重新设置 r.Body，使后续处理仍能读取
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, errors.New("event: request body too large to verify")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// ChainAuthenticator 依次尝试多个 Authenticator，返回第一个认证成功的结果
+type ChainAuthenticator []Authenticator
+
+// Authenticate 实现 Authenticator
+func (c ChainAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		if principal, err := a.Authenticate(r); err == nil && principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// Action 授权的操作
+type Action string
+
+const (
+	ActionPublish   Action = "publish"
+	ActionSubscribe Action = "subscribe"
+	ActionAdmin     Action = "admin" // 管理主题等
+)
+
+// Authorizer 判断调用方能否对主题执行操作，订阅时 topic 可以是带通配符的模式
+type Authorizer interface {
+	Authorize(principal *Principal, action Action, topic string) bool
+}
+
+// ACLRule 授权规则，TenantId 和 PrincipalId 为空表示匹配任意值，Topic 为主题模式
+type ACLRule struct {
+	TenantId    string   `json:"tenantId,omitempty" toml:"tenantId"`
+	PrincipalId string   `json:"principalId,omitempty" toml:"principalId"`
+	Topic       string   `json:"topic" toml:"topic"`
+	Actions     []Action `json:"actions" toml:"actions"`
+}
+
+// ACL 基于规则的 Authorizer，任意一条规则允许即授权
+type ACL struct {
+	Rules []ACLRule
+}
+
+// Authorize 实现 Authorizer
+func (a *ACL) Authorize(principal *Principal, action Action, topic string) bool {
+	if principal == nil {
+		return false
+	}
+	for _, rule := range a.Rules {
+		if rule.TenantId != "" && rule.TenantId != principal.TenantId {
+			continue
+		}
+		if rule.PrincipalId != "" && rule.PrincipalId != principal.Id {
+			continue
+		}
+		if !CoversTopic(rule.Topic, topic) {
+			continue
+		}
+		for _, a := range rule.Actions {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authenticate 认证中间件，未配置 Authenticator 时直接放行
+func (b *Broker) authenticate(c *gin.Context) {
+	if b.auth == nil {
+		c.Next()
+		return
+	}
+
+	principal, err := b.auth.Authenticate(c.Request)
+	if err != nil || principal == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Set(principalKey, principal)
+	c.Next()
+}
+
+// allowed 判断调用方能否对主题执行操作，未配置 Authorizer 时全部允许
+func (b *Broker) allowed(principal *Principal, action Action, topic string) bool {
+	return b.authorizer == nil || b.authorizer.Authorize(principal, action, topic)
+}
+
+// authorize 校验请求的权限，无权限时写入 403 响应
+func (b *Broker) authorize(c *gin.Context, action Action, topic string) bool {
+	if b.allowed(PrincipalFrom(c), action, topic) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": action, "topic": topic})
+	return false
+}
+
+// authorizeAdmin 主题管理接口的授权中间件
+func (b *Broker) authorizeAdmin(c *gin.Context) {
+	if b.authorize(c, ActionAdmin, "#") {
+		c.Next()
+	}
+}
+
+// checkPublisher 校验调用方能否发布事件：需要发布权限，且事件只能发布到请求所属租户 tenant。
+// 认证开启时清除 Payload.Authorization，避免凭证随事件推送给订阅者
+func (b *Broker) checkPublisher(principal *Principal, tenant string, event *Event) bool {
+	if !b.allowed(principal, ActionPublish, event.Topic) {
+		return false
+	}
+	if bindTenant(tenant, event) != nil {
+		return false
+	}
+	if b.auth != nil && event.Payload != nil {
+		event.Payload.Authorization = ""
+	}
+	return true
+}
+
+// withoutCredentials 返回去掉 Payload.Authorization 的事件副本，事件不含凭证时返回原事件，
+// 用于事件离开进程前（webhook、Kafka）避免泄露发布方的凭证
+func withoutCredentials(event *Event) *Event {
+	if event.Payload == nil || event.Payload.Authorization == "" {
+		return event
+	}
+	e := *event
+	p := *event.Payload
+	p.Authorization = ""
+	e.Payload = &p
+	return &e
+}
+
+// PrincipalFrom 返回请求的认证结果，未认证时返回 nil
+func PrincipalFrom(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package event
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gagraler/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL_Authorize(t *testing.T) {
+	acl := &ACL{Rules: []ACLRule{
+		{TenantId: "t1", Topic: "orders.#", Actions: []Action{ActionPublish, ActionSubscribe}},
+		{PrincipalId: "audit", Topic: "#", Actions: []Action{ActionSubscribe}},
+	}}
+
+	alice := &Principal{Id: "alice", TenantId: "t1"}
+	require.True(t, acl.Authorize(alice, ActionPublish, "orders.created"))
+	require.True(t, acl.Authorize(alice, ActionSubscribe, "orders.*"))
+	require.False(t, acl.Authorize(alice, ActionSubscribe, "#"))
+	require.False(t, acl.Authorize(alice, ActionAdmin, "orders.created"))
+	require.False(t, acl.Authorize(&Principal{Id: "bob", TenantId: "t2"}, ActionPublish, "orders.created"))
+
+	audit := &Principal{Id: "audit"}
+	require.True(t, acl.Authorize(audit, ActionSubscribe, ""))
+	require.False(t, acl.Authorize(audit, ActionPublish, "orders.created"))
+	require.False(t, acl.Authorize(nil, ActionSubscribe, "orders.created"))
+}
+
+func TestAuthenticators(t *testing.T) {
+	bearer := &BearerAuthenticator{Lookup: func(token string) (*Principal, error) {
+		if token == "good" {
+			return &Principal{Id: "alice"}, nil
+		}
+		return nil, ErrUnauthenticated
+	}}
+
+	const secret = "sk"
+	aksk := &AKSKAuthenticator{Lookup: func(accessKey string) (string, *Principal, error) {
+		return secret, &Principal{Id: accessKey}, nil
+	}}
+	chain := ChainAuthenticator{bearer, aksk}
+
+	req := httptest.NewRequest(http.MethodPost, "/publish", nil)
+	req.Header.Set("Authorization", "Bearer good")
+	p, err := chain.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, "alice", p.Id)
+
+	req = httptest.NewRequest(http.MethodGet, "/events?access_token=bad", nil)
+	_, err = chain.Authenticate(req)
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	ts := time.Now().Unix()
+	body := `{"topic":"orders"}`
+	signed := auth.Request{
+		Method:    http.MethodPost,
+		Path:      "/publish",
+		Query:     url.Values{"delay": {"1m"}},
+		Body:      []byte(body),
+		Timestamp: ts,
+	}
+	newRequest := func(query, body, signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/publish?"+query, strings.NewReader(body))
+		req.Header.Set(AccessKeyHeader, "ak")
+		req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+		req.Header.Set(SignHeader, signature)
+		return req
+	}
+
+	req = newRequest("delay=1m", body, auth.Sign(secret, signed))
+	p, err = chain.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, "ak", p.Id)
+	// 认证后请求体仍可读取
+	read, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, string(read))
+
+	// 方法、查询参数或请求体被修改时签名失效
+	wrongMethod := signed
+	wrongMethod.Method = http.MethodGet
+	_, err = chain.Authenticate(newRequest("delay=1m", body, auth.Sign(secret, wrongMethod)))
+	require.Error(t, err)
+	_, err = chain.Authenticate(newRequest("delay=1h", body, auth.Sign(secret, signed)))
+	require.Error(t, err)
+	_, err = chain.Authenticate(newRequest("delay=1m", `{"topic":"payments"}`, auth.Sign(secret, signed)))
+	require.Error(t, err)
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	a, err := url.ParseQuery("b=2&a=x y&b=1")
+	require.NoError(t, err)
+	b, err := url.ParseQuery("a=x+y&b=1&b=2")
+	require.NoError(t, err)
+	require.Equal(t, "a=x+y&b=1&b=2", auth.CanonicalQuery(a))
+	require.Equal(t, auth.CanonicalQuery(a), auth.CanonicalQuery(b))
+	require.Empty(t, auth.CanonicalQuery(nil))
+}
+
+func TestBroker_PublishAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	EventRouter(r.Group(""),
+		WithAuth(AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+			return &Principal{Id: "alice", TenantId: "t1"}, nil
+		})),
+		WithAuthorizer(&ACL{Rules: []ACLRule{{Topic: "orders.#", Actions: []Action{ActionPublish}}}}),
+	)
+
+	publish := func(body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(body)))
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, publish(`{"topic":"orders.created"}`))
+	require.Equal(t, http.StatusForbidden, publish(`{"topic":"users.created"}`))
+	require.Equal(t, http.StatusForbidden, publish(`{"topic":"orders.created","payload":{"tenantId":"t2"}}`))
+}
@@ -0,0 +1,224 @@
+package event
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	ws "github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// writeWait 单次 WebSocket 写入的超时时间
+const writeWait = 10 * time.Second
+
+// client 流式推送客户端，conn 为 nil 时表示 SSE 客户端
+type client struct {
+	id          string
+	conn        *ws.Conn
+	remote      string
+	connectedAt time.Time
+	principal   *Principal          // 认证后的调用方，未开启认证时为 nil
+	tenant      string              // 所属租户，只接收该租户的事件
+	topics      map[string]struct{} // 订阅的主题模式，由 b.mu 保护
+	out         *outbox
+	protocol    bool          // 是否使用 ProtocolV1 控制协议
+	format      string        // 事件格式，FormatNative 或 FormatCloudEvents
+	ctrl        chan Frame    // 待发送的控制帧
+	done        chan struct{} // 写协程退出时关闭
+
+	closeCode int    // 队列关闭后发送的 WebSocket 关闭码，为 0 时不发送关闭帧，在 b.mu 内写入
+	closeText string // 关闭原因
+
+	acked     atomic.Uint64 // 最近确认的事件序号
+	ackCount  atomic.Int64  // 累计确认次数
+	bytesSent atomic.Int64  // 累计发送的字节数
+}
+
+// matches 判断客户端是否订阅了该主题，调用方需持有 b.mu
+func (c *client) matches(topic string) bool {
+	for pattern := range c.topics {
+		if MatchTopic(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// close 关闭客户端队列，WebSocket 客户端同时关闭连接以中断阻塞中的读写
+func (c *client) close() {
+	c.out.close()
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+// send 将控制帧交给写协程，写协程已退出时丢弃
+func (c *client) send(frame Frame) {
+	select {
+	case c.ctrl <- frame:
+	case <-c.done:
+	}
+}
+
+// encode 按客户端选择的格式编码事件
+func (c *client) encode(event *Event) ([]byte, error) {
+	if c.format == FormatCloudEvents {
+		return MarshalCloudEvent(event)
+	}
+	return json.Marshal(event)
+}
+
+// write 写入一条消息，控制协议下事件以 event 帧包装
+func (c *client) write(v interface{}) error {
+	var (
+		data []byte
+		err  error
+	)
+	event, ok := v.(*Event)
+	switch {
+	case !ok:
+		data, err = json.Marshal(v)
+	case c.protocol:
+		data, err = json.Marshal(Frame{Type: FrameEvent, Event: event})
+	default:
+		data, err = c.encode(event)
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.conn.WriteMessage(ws.TextMessage, data); err != nil {
+		return err
+	}
+	c.bytesSent.Add(int64(len(data)))
+	return nil
+}
+
+// addClient 创建并注册租户 tenant 的客户端，topic 为空字符串表示订阅所有主题，调用方需持有 b.mu 并已通过 acquireConn 占用连接名额
+func (b *Broker) addClient(conn *ws.Conn, remote string, principal *Principal, tenant string, topics ...string) *client {
+	c := b.newClient(conn, remote, principal, tenant, topics...)
+	b.registerClient(c)
+	return c
+}
+
+// newClient 创建尚未注册的客户端，注册前不会收到推送
+func (b *Broker) newClient(conn *ws.Conn, remote string, principal *Principal, tenant string, topics ...string) *client {
+	c := &client{
+		id:          uuid.NewString(),
+		conn:        conn,
+		remote:      remote,
+		connectedAt: time.Now(),
+		principal:   principal,
+		tenant:      tenant,
+		topics:      make(map[string]struct{}, len(topics)),
+		out:         newOutbox(b.queueOptions),
+		ctrl:        make(chan Frame, 16),
+		done:        make(chan struct{}),
+	}
+	if conn != nil {
+		c.protocol = conn.Subprotocol() == ProtocolV1
+	}
+	for _, topic := range topics {
+		c.topics[topic] = struct{}{}
+	}
+	return c
+}
+
+// registerClient 注册客户端，调用方需持有 b.mu
+func (b *Broker) registerClient(c *client) {
+	// 关闭过程中建立的连接不再注册，写协程启动后立即断开
+	if b.closing.Load() {
+		c.closeCode, c.closeText = ws.CloseGoingAway, shutdownReason
+		c.out.close()
+		return
+	}
+	b.clients[c] = struct{}{}
+}
+
+// removeClient 注销客户端并关闭其队列，写协程在队列耗尽后关闭连接
+func (b *Broker) removeClient(c *client) {
+	b.mu.Lock()
+	b.detachClient(c)
+	b.mu.Unlock()
+	c.out.close()
+}
+
+// detachClient 注销客户端并释放其租户连接名额，可重复调用，调用方需持有 b.mu
+func (b *Broker) detachClient(c *client) {
+	if _, ok := b.clients[c]; ok {
+		delete(b.clients, c)
+		b.tenants.releaseConn(c.tenant)
+	}
+}
+
+// serveClient 先写入回放事件，再启动写协程和读协程
+func (b *Broker) serveClient(c *client, replay []*Event) {
+	for _, event := range replay {
+		if err := c.write(event); err != nil {
+			b.removeClient(c)
+			close(c.done)
+			_ = c.conn.Close()
+			return
+		}
+	}
+
+	go b.writeLoop(c)
+	go func() {
+		defer b.removeClient(c)
+		if c.protocol {
+			b.readFrames(c)
+			return
+		}
+		// 未协商控制协议的客户端只接收推送，忽略收到的消息
+		for {
+			if _, _, err := c.conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// writeLoop 客户端的写协程，是连接上唯一的写入者
+func (b *Broker) writeLoop(c *client) {
+	defer func() {
+		close(c.done)
+		_ = c.conn.Close()
+	}()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-c.out.ch:
+			if !ok {
+				b.writeClose(c)
+				return
+			}
+			err = c.write(event)
+		case frame := <-c.ctrl:
+			err = c.write(frame)
+		}
+		if err != nil {
+			b.logger.Warn("Error writing to WebSocket", zap.String("remote", c.remote), zap.Error(err))
+			b.removeClient(c)
+			// 排空队列，避免残留事件占用内存
+			for range c.out.ch {
+			}
+			return
+		}
+	}
+}
+
+// writeClose 队列关闭后按需发送关闭帧
+func (b *Broker) writeClose(c *client) {
+	b.mu.Lock()
+	code, text := c.closeCode, c.closeText
+	b.mu.Unlock()
+	if code == 0 {
+		return
+	}
+	deadline := time.Now().Add(writeWait)
+	_ = c.conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(code, text), deadline)
+}
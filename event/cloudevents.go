@@ -0,0 +1,363 @@
+package event
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// CloudEventsSpecVersion 支持的 CloudEvents 规范版本
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType CloudEvents structured 模式的 JSON 内容类型
+	CloudEventsContentType = "application/cloudevents+json"
+	// DefaultCloudEventSource 事件未携带 ResourceUri 时使用的 source
+	DefaultCloudEventSource = "/event"
+
+	// cloudEventsHeaderPrefix binary 模式下属性对应的 HTTP 头前缀
+	cloudEventsHeaderPrefix = "Ce-"
+)
+
+// 事件的投递格式
+const (
+	// FormatNative 原始 Event JSON
+	FormatNative = ""
+	// FormatCloudEvents CloudEvents structured JSON
+	FormatCloudEvents = "cloudevents"
+	// FormatCloudEventsBinary CloudEvents binary HTTP 模式，仅适用于 webhook 推送
+	FormatCloudEventsBinary = "cloudevents-binary"
+)
+
+// Payload 字段与 CloudEvents 扩展属性的对应关系，扩展属性名须为小写字母和数字
+const (
+	extTopic            = "topic"
+	extCorrelationId    = "correlationid"
+	extResourceProvider = "resourceprovider"
+	extOperationName    = "operationname"
+	extState            = "state"
+	extSubscriptionId   = "subscriptionid"
+	extTenantId         = "tenantid"
+	extReplyTo          = "replyto"
+)
+
+// ErrInvalidCloudEvent 缺少 CloudEvents 必需属性或版本不支持
+var ErrInvalidCloudEvent = errors.New("event: invalid cloudevent")
+
+// CloudEvent CloudEvents 1.0 事件，Extensions 在 JSON 中与标准属性平铺
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	Id              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            *time.Time        `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage   `json:"data,omitempty"`
+	DataBase64      []byte            `json:"data_base64,omitempty"`
+	Extensions      map[string]string `json:"-"`
+}
+
+// cloudEventAttributes 避免 MarshalJSON 递归
+type cloudEventAttributes CloudEvent
+
+// MarshalJSON 将扩展属性平铺到顶层
+func (ce CloudEvent) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(cloudEventAttributes(ce))
+	if err != nil || len(ce.Extensions) == 0 {
+		return data, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range ce.Extensions {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON 将非标准属性收集到 Extensions，data 与 data_base64 不能同时出现
+func (ce *CloudEvent) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if encoded, ok := fields["data_base64"]; ok {
+		if _, ok := fields["data"]; ok {
+			return fmt.Errorf("%w: data and data_base64 are mutually exclusive", ErrInvalidCloudEvent)
+		}
+		var s string
+		if err := json.Unmarshal(encoded, &s); err != nil {
+			return fmt.Errorf("%w: invalid data_base64", ErrInvalidCloudEvent)
+		}
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return fmt.Errorf("%w: invalid data_base64", ErrInvalidCloudEvent)
+		}
+	}
+
+	var attrs cloudEventAttributes
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		switch k {
+		case "specversion", "id", "source", "type", "subject", "time", "datacontenttype", "data", "data_base64", "dataschema":
+			continue
+		}
+		if attrs.Extensions == nil {
+			attrs.Extensions = make(map[string]string)
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			s = string(v)
+		}
+		attrs.Extensions[k] = s
+	}
+
+	*ce = CloudEvent(attrs)
+	return nil
+}
+
+// ToCloudEvent 将 Event 转换为 CloudEvent，Payload 中的字段作为扩展属性保存，
+// Authorization 属于凭证不会被转换，[]byte 类型的 Detail 作为 data_base64 保存
+func ToCloudEvent(event *Event) (*CloudEvent, error) {
+	ce := &CloudEvent{
+		SpecVersion: CloudEventsSpecVersion,
+		Id:          event.Id,
+		Source:      DefaultCloudEventSource,
+		Type:        event.EventType,
+		Subject:     event.Subject,
+		Extensions:  map[string]string{extTopic: event.Topic},
+	}
+	if ce.Type == "" {
+		ce.Type = event.Topic
+	}
+	if !event.EventTime.IsZero() {
+		t := event.EventTime
+		ce.Time = &t
+	}
+	if raw, ok := event.Detail.([]byte); ok {
+		ce.DataBase64 = raw
+		ce.DataContentType = "application/octet-stream"
+	} else if event.Detail != nil {
+		data, err := json.Marshal(event.Detail)
+		if err != nil {
+			return nil, err
+		}
+		ce.Data = data
+		ce.DataContentType = "application/json"
+	}
+
+	if p := event.Payload; p != nil {
+		if p.ResourceUri != "" {
+			ce.Source = p.ResourceUri
+		}
+		for k, v := range map[string]string{
+			extCorrelationId:    p.CorrelationId,
+			extResourceProvider: p.ResourceProvider,
+			extOperationName:    p.OperationName,
+			extState:            p.State,
+			extSubscriptionId:   p.SubscriptionId,
+			extTenantId:         p.TenantId,
+			extReplyTo:          p.ReplyTo,
+		} {
+			if v != "" {
+				ce.Extensions[k] = v
+			}
+		}
+	}
+
+	return ce, nil
+}
+
+// FromCloudEvent 将 CloudEvent 转换为 Event，缺少 topic 扩展属性时使用 type 作为主题；
+// data_base64 仅在内容类型为 JSON 时解析，否则按 []byte 保存
+func FromCloudEvent(ce *CloudEvent) (*Event, error) {
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("%w: unsupported specversion %q", ErrInvalidCloudEvent, ce.SpecVersion)
+	}
+	if ce.Id == "" || ce.Source == "" || ce.Type == "" {
+		return nil, fmt.Errorf("%w: id, source and type are required", ErrInvalidCloudEvent)
+	}
+
+	ext := ce.Extensions
+	event := &Event{
+		Topic:     ext[extTopic],
+		Subject:   ce.Subject,
+		EventType: ce.Type,
+		Id:        ce.Id,
+	}
+	if event.Topic == "" {
+		event.Topic = ce.Type
+	}
+	if ce.Time != nil {
+		event.EventTime = *ce.Time
+	}
+	if len(ce.Data) > 0 {
+		if err := json.Unmarshal(ce.Data, &event.Detail); err != nil {
+			// 非 JSON 数据按字符串保存
+			event.Detail = string(ce.Data)
+		}
+	} else if len(ce.DataBase64) > 0 {
+		event.Detail = ce.DataBase64
+		if isJSONContentType(ce.DataContentType) {
+			if err := json.Unmarshal(ce.DataBase64, &event.Detail); err != nil {
+				return nil, fmt.Errorf("%w: invalid data_base64 json", ErrInvalidCloudEvent)
+			}
+		}
+	}
+
+	payload := Payload{
+		CorrelationId:    ext[extCorrelationId],
+		ResourceProvider: ext[extResourceProvider],
+		OperationName:    ext[extOperationName],
+		State:            ext[extState],
+		SubscriptionId:   ext[extSubscriptionId],
+		TenantId:         ext[extTenantId],
+		ReplyTo:          ext[extReplyTo],
+	}
+	if ce.Source != DefaultCloudEventSource {
+		payload.ResourceUri = ce.Source
+	}
+	if payload != (Payload{}) {
+		event.Payload = &payload
+	}
+
+	return event, nil
+}
+
+// MarshalCloudEvent 将 Event 编码为 CloudEvents structured JSON
+func MarshalCloudEvent(event *Event) ([]byte, error) {
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ce)
+}
+
+// UnmarshalCloudEvent 解码 CloudEvents structured JSON
+func UnmarshalCloudEvent(data []byte) (*Event, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return nil, err
+	}
+	return FromCloudEvent(&ce)
+}
+
+// WriteBinaryCloudEvent 以 binary 模式编码 Event：属性按百分号编码写入 ce-* 请求头，返回作为请求体的数据
+func WriteBinaryCloudEvent(header http.Header, event *Event) ([]byte, error) {
+	ce, err := ToCloudEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Set(cloudEventsHeaderPrefix+"Specversion", ce.SpecVersion)
+	header.Set(cloudEventsHeaderPrefix+"Id", encodeHeaderValue(ce.Id))
+	header.Set(cloudEventsHeaderPrefix+"Source", encodeHeaderValue(ce.Source))
+	header.Set(cloudEventsHeaderPrefix+"Type", encodeHeaderValue(ce.Type))
+	if ce.Subject != "" {
+		header.Set(cloudEventsHeaderPrefix+"Subject", encodeHeaderValue(ce.Subject))
+	}
+	if ce.Time != nil {
+		header.Set(cloudEventsHeaderPrefix+"Time", ce.Time.Format(time.RFC3339Nano))
+	}
+	for k, v := range ce.Extensions {
+		header.Set(cloudEventsHeaderPrefix+k, encodeHeaderValue(v))
+	}
+	if ce.DataContentType != "" {
+		header.Set("Content-Type", ce.DataContentType)
+	}
+
+	if ce.DataBase64 != nil {
+		return ce.DataBase64, nil
+	}
+	return ce.Data, nil
+}
+
+// ReadBinaryCloudEvent 从 ce-* 请求头和请求体解码 binary 模式的 CloudEvent，请求头的值按百分号编码解码
+func ReadBinaryCloudEvent(header http.Header, body []byte) (*Event, error) {
+	ce := &CloudEvent{
+		DataContentType: header.Get("Content-Type"),
+		Extensions:      make(map[string]string),
+	}
+	if len(body) > 0 {
+		ce.Data = body
+	}
+
+	for k, v := range header {
+		if len(v) == 0 || !strings.HasPrefix(http.CanonicalHeaderKey(k), cloudEventsHeaderPrefix) {
+			continue
+		}
+		name := strings.ToLower(k[len(cloudEventsHeaderPrefix):])
+		value, err := url.PathUnescape(v[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid header %s", ErrInvalidCloudEvent, k)
+		}
+		switch name {
+		case "specversion":
+			ce.SpecVersion = value
+		case "id":
+			ce.Id = value
+		case "source":
+			ce.Source = value
+		case "type":
+			ce.Type = value
+		case "subject":
+			ce.Subject = value
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid time %q", ErrInvalidCloudEvent, value)
+			}
+			ce.Time = &t
+		default:
+			ce.Extensions[name] = value
+		}
+	}
+
+	return FromCloudEvent(ce)
+}
+
+// encodeHeaderValue 按 CloudEvents HTTP 绑定对请求头的值做百分号编码：
+// 空格、双引号、百分号以及可打印 ASCII 之外的字节均需编码
+func encodeHeaderValue(v string) string {
+	var sb strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// isJSONContentType 判断内容类型是否为 JSON
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// IsBinaryCloudEvent 判断请求是否为 binary 模式的 CloudEvent
+func IsBinaryCloudEvent(header http.Header) bool {
+	return header.Get(cloudEventsHeaderPrefix+"Specversion") != ""
+}
+
+// ValidFormat 判断投递格式是否受支持
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatNative, FormatCloudEvents, FormatCloudEventsBinary:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package event
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEvent() *Event {
+	return &Event{
+		Topic:     "orders.created",
+		Subject:   "order/42",
+		EventType: "com.example.order.created",
+		EventTime: time.Date(2024, 7, 14, 20, 47, 0, 0, time.UTC),
+		Id:        "e1",
+		Payload: &Payload{
+			Authorization: "Bearer secret",
+			CorrelationId: "c1",
+			ResourceUri:   "/orders/42",
+			TenantId:      "t1",
+		},
+		Detail: map[string]interface{}{"amount": float64(10)},
+	}
+}
+
+func TestCloudEvent_Structured(t *testing.T) {
+	data, err := MarshalCloudEvent(newTestEvent())
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	require.Equal(t, "1.0", fields["specversion"])
+	require.Equal(t, "/orders/42", fields["source"])
+	require.Equal(t, "t1", fields["tenantid"])
+	require.NotContains(t, fields, "authorization")
+
+	event, err := UnmarshalCloudEvent(data)
+	require.NoError(t, err)
+	want := newTestEvent()
+	want.Payload.Authorization = ""
+	require.Equal(t, want, event)
+}
+
+func TestCloudEvent_Binary(t *testing.T) {
+	header := make(http.Header)
+	body, err := WriteBinaryCloudEvent(header, newTestEvent())
+	require.NoError(t, err)
+	require.True(t, IsBinaryCloudEvent(header))
+	require.Equal(t, "e1", header.Get("ce-id"))
+	require.Equal(t, "application/json", header.Get("Content-Type"))
+
+	event, err := ReadBinaryCloudEvent(header, body)
+	require.NoError(t, err)
+	want := newTestEvent()
+	want.Payload.Authorization = ""
+	require.Equal(t, want, event)
+}
+
+func TestCloudEvent_Invalid(t *testing.T) {
+	_, err := UnmarshalCloudEvent([]byte(`{"specversion":"0.3","id":"1","source":"/","type":"t"}`))
+	require.ErrorIs(t, err, ErrInvalidCloudEvent)
+
+	_, err = UnmarshalCloudEvent([]byte(`{"specversion":"1.0","source":"/","type":"t"}`))
+	require.ErrorIs(t, err, ErrInvalidCloudEvent)
+}
+
+func TestCloudEvent_DataBase64(t *testing.T) {
+	event := newTestEvent()
+	event.Detail = []byte{0x00, 0xff, 'a'}
+	data, err := MarshalCloudEvent(event)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	require.Equal(t, "AP9h", fields["data_base64"])
+	require.NotContains(t, fields, "data")
+
+	decoded, err := UnmarshalCloudEvent(data)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0xff, 'a'}, decoded.Detail)
+
+	// 内容类型为 JSON 时解析为 JSON
+	decoded, err = UnmarshalCloudEvent([]byte(`{"specversion":"1.0","id":"1","source":"/","type":"t","datacontenttype":"application/json","data_base64":"eyJhIjoxfQ=="}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": float64(1)}, decoded.Detail)
+
+	_, err = UnmarshalCloudEvent([]byte(`{"specversion":"1.0","id":"1","source":"/","type":"t","data_base64":"!!"}`))
+	require.ErrorIs(t, err, ErrInvalidCloudEvent)
+
+	_, err = UnmarshalCloudEvent([]byte(`{"specversion":"1.0","id":"1","source":"/","type":"t","data":1,"data_base64":"AA=="}`))
+	require.ErrorIs(t, err, ErrInvalidCloudEvent)
+}
+
+func TestCloudEvent_BinaryHeaderEncoding(t *testing.T) {
+	event := newTestEvent()
+	event.Subject = `订单 "42" 100%`
+	event.Payload.State = "已支付"
+
+	header := make(http.Header)
+	body, err := WriteBinaryCloudEvent(header, event)
+	require.NoError(t, err)
+	require.Equal(t, "%E8%AE%A2%E5%8D%95%20%2242%22%20100%25", header.Get("ce-subject"))
+	for _, v := range header {
+		for _, c := range v[0] {
+			require.True(t, c > ' ' && c <= '~', v[0])
+		}
+	}
+
+	decoded, err := ReadBinaryCloudEvent(header, body)
+	require.NoError(t, err)
+	require.Equal(t, event.Subject, decoded.Subject)
+	require.Equal(t, "已支付", decoded.Payload.State)
+
+	header.Set("ce-state", "%zz")
+	_, err = ReadBinaryCloudEvent(header, body)
+	require.ErrorIs(t, err, ErrInvalidCloudEvent)
+}
@@ -0,0 +1,117 @@
+package event
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateEvent 去重窗口内已发布过相同 Id 的事件
+var ErrDuplicateEvent = errors.New("event: duplicate event id")
+
+// DedupMode 发布重复事件时的处理方式
+type DedupMode int
+
+const (
+	// DedupCollapse 忽略重复事件，Publish 返回 nil，适合幂等重试
+	DedupCollapse DedupMode = iota
+	// DedupReject 拒绝重复事件，Publish 返回 ErrDuplicateEvent
+	DedupReject
+)
+
+// seenId 按记录时间排列的 Id
+type seenId struct {
+	id string
+	at time.Time
+}
+
+// Deduplicator 记住最近 window 内出现过的 Id，可用于订阅者对重复投递去重
+type Deduplicator struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	order  []seenId // 按记录时间排列，用于淘汰过期 Id
+	now    func() time.Time
+}
+
+// NewDeduplicator 创建记住 window 内 Id 的去重器
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Seen 判断 id 是否在窗口内出现过，未出现过时记录 id 并返回 false
+func (d *Deduplicator) Seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.expire(now)
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+	d.seen[id] = now
+	d.order = append(d.order, seenId{id: id, at: now})
+	return false
+}
+
+// Forget 删除 id 的记录，处理失败时调用，使重试不被视为重复
+func (d *Deduplicator) Forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, id)
+}
+
+// Handler 包装订阅者，跳过窗口内 Id 重复的事件，未指定 Id 的事件总会被处理。
+// 订阅者 panic 时删除该 Id 的记录，重新投递的事件会被再次处理
+func (d *Deduplicator) Handler(handler func(*Event)) func(*Event) {
+	h := d.WrapHandler(handlerFunc(handler))
+	return func(event *Event) {
+		_ = h(event)
+	}
+}
+
+// WrapHandler 包装 SubscribeHandler 的处理函数，跳过窗口内 Id 重复的事件，未指定 Id 的事件总会被处理。
+// 处理函数返回 error 或 panic 时删除该 Id 的记录，重试或重新投递的事件会被再次处理
+func (d *Deduplicator) WrapHandler(handler Handler) Handler {
+	return func(event *Event) (err error) {
+		if event.Id == "" {
+			return handler(event)
+		}
+		key := dedupKey(event)
+		if d.Seen(key) {
+			return nil
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				d.Forget(key)
+				panic(r)
+			}
+			if err != nil {
+				d.Forget(key)
+			}
+		}()
+		return handler(event)
+	}
+}
+
+// expire 淘汰超出窗口的 Id，调用方需持有 d.mu
+func (d *Deduplicator) expire(now time.Time) {
+	i := 0
+	for ; i < len(d.order) && now.Sub(d.order[i].at) >= d.window; i++ {
+		// Forget 后重新记录的 Id 以最新时间为准
+		if at, ok := d.seen[d.order[i].id]; ok && at.Equal(d.order[i].at) {
+			delete(d.seen, d.order[i].id)
+		}
+	}
+	d.order = d.order[i:]
+}
+
+// dedupKey 事件的去重键，不同租户的 Id 互不影响
+func dedupKey(event *Event) string {
+	return tenantOf(event) + "/" + event.Id
+}
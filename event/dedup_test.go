@@ -0,0 +1,86 @@
+package event
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := NewDeduplicator(time.Minute)
+	d.now = func() time.Time { return now }
+
+	require.False(t, d.Seen("a"))
+	require.True(t, d.Seen("a"))
+
+	d.Forget("a")
+	require.False(t, d.Seen("a"))
+
+	now = now.Add(time.Minute)
+	require.False(t, d.Seen("a"))
+	require.Len(t, d.order, 1)
+
+	var handled int
+	h := d.Handler(func(*Event) { handled++ })
+	h(&Event{Id: "b"})
+	h(&Event{Id: "b"})
+	h(&Event{Id: "b", Payload: &Payload{TenantId: "t1"}})
+	h(&Event{})
+	h(&Event{})
+	require.Equal(t, 4, handled)
+}
+
+func TestDeduplicator_WrapHandler(t *testing.T) {
+	d := NewDeduplicator(time.Minute)
+
+	var calls int
+	fail := errors.New("fail")
+	h := d.WrapHandler(func(event *Event) error {
+		calls++
+		switch calls {
+		case 1:
+			return fail
+		case 2:
+			panic("boom")
+		}
+		return nil
+	})
+
+	// 失败和 panic 后重新投递的事件仍被处理，成功后的重复事件被跳过
+	require.ErrorIs(t, h(&Event{Id: "a"}), fail)
+	require.Panics(t, func() { _ = h(&Event{Id: "a"}) })
+	require.NoError(t, h(&Event{Id: "a"}))
+	require.NoError(t, h(&Event{Id: "a"}))
+	require.Equal(t, 3, calls)
+
+	var handled int
+	legacy := d.Handler(func(*Event) {
+		handled++
+		if handled == 1 {
+			panic("boom")
+		}
+	})
+	require.Panics(t, func() { legacy(&Event{Id: "b"}) })
+	legacy(&Event{Id: "b"})
+	legacy(&Event{Id: "b"})
+	require.Equal(t, 2, handled)
+}
+
+func TestBroker_PublishDedup(t *testing.T) {
+	b := NewBroker(WithDedupWindow(time.Minute, DedupCollapse))
+
+	event := &Event{Topic: "orders"}
+	require.NoError(t, b.Publish(event))
+	require.NotEmpty(t, event.Id)
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: event.Id}))
+	require.Len(t, b.Consume(), 1)
+
+	b = NewBroker(WithDedupWindow(time.Minute, DedupReject))
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "1"}))
+	require.ErrorIs(t, b.Publish(&Event{Topic: "orders", Id: "1"}), ErrDuplicateEvent)
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "1", Payload: &Payload{TenantId: "t1"}}))
+}
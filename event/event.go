@@ -1,11 +1,14 @@
 package event
 
 import (
+	"context"
+	"github.com/google/uuid"
 	ws "github.com/gorilla/websocket"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,6 +33,8 @@ type Event struct {
 	EventType string      `json:"eventType,omitempty"`
 	EventTime time.Time   `json:"eventTime"`
 	Id        string      `json:"id,omitempty"`
+	Offset    int64       `json:"offset,omitempty"`
+	Sequence  uint64      `json:"sequence,omitempty"` // 持久化后的全局序号，跨主题递增
 	Payload   *Payload    `json:"payload"`
 	Detail    interface{} `json:"detail,omitempty"`
 }
@@ -43,22 +48,68 @@ type Payload struct {
 	State            string `json:"state,omitempty"`
 	SubscriptionId   string `json:"subscriptionId,omitempty"`
 	TenantId         string `json:"tenantId,omitempty"`
+	ReplyTo          string `json:"replyTo,omitempty"` // 请求事件期望的回复主题，见 Broker.Request
 }
 
 // Broker 内存中的消息代理
 type Broker struct {
 	mu       sync.Mutex
-	topics   map[string][]func(*Event)
-	eventsCh chan *Event
-	clients  map[*ws.Conn]string // 客户端连接与订阅的主题
+	topics   map[string][]*listener
+	eventsCh *outbox
+	clients  map[*client]struct{} // WebSocket 和 SSE 客户端
 	db       *gorm.DB
+
+	logger     *zap.Logger
+	upgrader   *ws.Upgrader
+	auth       Authenticator // 为 nil 时不做认证
+	authorizer Authorizer    // 为 nil 时不做授权
+
+	heartbeatInterval time.Duration // SSE 心跳间隔
+
+	requireTopic bool        // 是否要求事件主题已注册
+	schemas      schemaCache // 主题 JSON Schema 缓存
+
+	tenants tenants // 租户配额及用量
+
+	scheduleInterval time.Duration // 检查到期定时事件的间隔
+
+	dedup     *Deduplicator // 为 nil 时不对发布去重
+	dedupMode DedupMode
+
+	stats  map[topicKey]*topicCounters // 各租户主题的计数，由 mu 保护
+	paused map[topicKey]struct{}       // 暂停的租户主题模式，由 mu 保护
+	errors errorLog                    // 最近的发布和推送错误
+
+	ctx      context.Context // Shutdown 时取消，用于结束后台任务
+	cancel   context.CancelFunc
+	closing  atomic.Bool    // 是否已开始关闭，不再接受外部发布、订阅和连接，在 mu 内写入
+	closed   atomic.Bool    // 是否已关闭各队列，内部发布（如死信事件）也被拒绝，在 mu 内写入
+	inflight atomic.Int64   // 进行中的发布、webhook 推送、等待重试的事件以及进程内订阅者队列中的事件
+	wg       sync.WaitGroup // 进程内订阅者的处理协程
+
+	replayGate sync.RWMutex // 发布从持久化到入队期间持有读锁，回放的最后一次读取持有写锁
+	topicLocks sync.Map     // 按租户主题串行化持久化与入队，同一主题的事件按 offset 顺序推送
+
+	pending map[string]*pendingRequest // 按租户和 CorrelationId 索引的等待回复的请求，由 mu 保护
+
+	queueOptions QueueOptions // 每个订阅者出站队列的配置
+	dropped      atomic.Int64 // 因队列已满丢弃的事件数
+
+	httpClient  *http.Client            // 推送 webhook 使用的客户端
+	retryPolicy RetryPolicy             // webhook 推送重试策略
+	webhooks    map[uint]*webhookWorker // 按订阅 ID 索引的推送队列，由 mu 保护
 }
 
-// Subscription 模型
+// Subscription 模型，CallbackUrl 非空时事件通过 HTTP 推送给订阅者，只接收所属租户的事件
 type Subscription struct {
 	gorm.Model
-	Topic  string `gorm:"uniqueIndex:idx_topic_client_id;not null"`
-	Client string `gorm:"uniqueIndex:idx_topic_client_id;not null"`
+	TenantId    string `gorm:"uniqueIndex:idx_tenant_topic_client;size:64;not null;default:''" json:"tenantId,omitempty"`
+	Topic       string `gorm:"uniqueIndex:idx_tenant_topic_client;size:255;not null" json:"topic"`
+	Client      string `gorm:"uniqueIndex:idx_tenant_topic_client;size:255;not null" json:"client"`
+	CallbackUrl string `gorm:"size:1024" json:"callbackUrl,omitempty"`
+	Secret      string `gorm:"size:255" json:"-"`
+	ContentType string `gorm:"size:128" json:"contentType,omitempty"`
+	Format      string `gorm:"size:32" json:"format,omitempty"` // 推送格式，见 FormatCloudEvents 等
 }
 
 var upgrade = ws.Upgrader{
@@ -69,68 +120,231 @@ var upgrade = ws.Upgrader{
 	},
 }
 
-func NewBroker() *Broker {
-	return &Broker{
-		topics:   make(map[string][]func(*Event)),
-		eventsCh: make(chan *Event, 100),
-		clients:  make(map[*ws.Conn]string),
-		// todo: db
+// NewBroker 创建 Broker，默认不持久化事件，需要数据库的功能通过 WithDB 启用
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		topics:  make(map[string][]*listener),
+		clients: make(map[*client]struct{}),
+		stats:   make(map[topicKey]*topicCounters),
+		paused:  make(map[topicKey]struct{}),
+
+		webhooks: make(map[uint]*webhookWorker),
+
+		logger:       zap.L(),
+		upgrader:     &upgrade,
+		queueOptions: DefaultQueueOptions,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		retryPolicy:  DefaultRetryPolicy,
+
+		heartbeatInterval: DefaultHeartbeatInterval,
+		scheduleInterval:  DefaultScheduleInterval,
+	}
+	for _, opt := range opts {
+		opt(b)
 	}
+	b.eventsCh = newOutbox(QueueOptions{Size: b.queueOptions.Size, Policy: DropOldest})
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	return b
 }
 
-func (b *Broker) Publish(event *Event) error {
-	// 发布事件到通道
-	b.eventsCh <- event
+// NewBrokerWithDB 创建使用数据库持久化事件和订阅的 Broker
+//
+// Deprecated: 使用 NewBroker(WithDB(db))
+func NewBrokerWithDB(db *gorm.DB) *Broker {
+	return NewBroker(WithDB(db))
+}
 
-	// 分发事件给订阅者
+// SetQueueOptions 设置之后注册的订阅者的出站队列大小和溢出策略
+func (b *Broker) SetQueueOptions(opts QueueOptions) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.queueOptions = opts
+}
 
-	for topic, handlers := range b.topics {
-		if topic == event.Topic {
-			for _, handler := range handlers {
-				go handler(event) // 异步处理事件
-			}
+// Publish 发布事件，事件按 Payload.TenantId 归属租户，只投递给同一租户的订阅者。
+// 未指定 Id 时自动生成；通过 WithDedupWindow 开启去重后，窗口内 Id 重复的事件按 DedupMode 处理
+func (b *Broker) Publish(event *Event) error {
+	if b.closing.Load() {
+		return ErrBrokerClosed
+	}
+	return b.publishEvent(event)
+}
+
+// publishEvent 发布事件，关闭过程中仍可用于死信等内部事件，直到队列关闭
+func (b *Broker) publishEvent(event *Event) error {
+	if !b.tenants.allowPublish(tenantOf(event)) {
+		return ErrQuotaExceeded
+	}
+	if b.isPaused(event) {
+		return ErrTopicPaused
+	}
+
+	if event.Id == "" {
+		event.Id = uuid.NewString()
+	}
+	if b.dedup != nil && b.dedup.Seen(dedupKey(event)) {
+		if b.dedupMode == DedupReject {
+			return ErrDuplicateEvent
 		}
+		return nil
 	}
 
-	// 分发事件给 WebSocket 客户端
-	for conn, clientTopic := range b.clients {
-		if clientTopic == event.Topic {
-			err := conn.WriteJSON(event)
-			if err != nil {
-				log.Printf("Error writing to WebSocket: %v", err)
-				delete(b.clients, conn)
-				err = conn.Close()
-				if err != nil {
-					return err
-				}
-			}
+	if err := b.publish(event); err != nil {
+		b.recordError("publish", event, err)
+		// 发布失败时允许以相同 Id 重试
+		if b.dedup != nil {
+			b.dedup.Forget(dedupKey(event))
 		}
+		return err
+	}
+	return nil
+}
+
+// publish 校验、分发并推送事件
+func (b *Broker) publish(event *Event) error {
+	if err := b.validate(event); err != nil {
+		return err
+	}
+
+	if err := b.dispatch(event); err != nil {
+		return err
 	}
+	defer b.inflight.Add(-1)
 
-	// 从数据库中读取所有订阅者并推送事件
+	if b.db == nil {
+		return nil
+	}
+
+	// 从数据库中读取本租户精确匹配及带通配符的订阅者并推送事件
 	var subscriptions []Subscription
-	if err := b.db.Where("topic = ? OR topic = ?", event.Topic, "*").Find(&subscriptions).Error; err != nil {
-		log.Printf("Failed to fetch subscriptions: %v", err)
+	err := b.db.Where("tenant_id = ?", tenantOf(event)).
+		Where("topic IN ? OR topic LIKE ? OR topic LIKE ?", []string{event.Topic, ""}, "%*%", "%#%").
+		Find(&subscriptions).Error
+	if err != nil {
+		b.logger.Error("Failed to fetch subscriptions", zap.Error(err))
 		return err
 	}
 
 	for _, sub := range subscriptions {
-		// 这里可以添加逻辑来推送事件给订阅者
-		// 例如，通过 HTTP 请求或其他方式
-		log.Printf("Pushing event to subscriber: %+v", sub)
+		if sub.CallbackUrl == "" || !MatchTopic(sub.Topic, event.Topic) {
+			continue
+		}
+		b.enqueueWebhook(sub, event) // 异步推送，重试不阻塞发布
+	}
+
+	return nil
+}
+
+// dispatch 持久化事件并放入各订阅者的出站队列，入队不阻塞，慢订阅者不会拖慢发布者。
+// 持久化在 b.mu 之外进行，只有同一租户主题的发布相互等待；从持久化到入队期间持有 replayGate 读锁，
+// 回放的最后一次读取不会读到已持久化但尚未入队的事件，因此回放与实时推送之间不会遗漏或重复。
+// 成功时登记一次进行中的发布，调用方推送完 webhook 后调用 b.inflight.Add(-1)
+func (b *Broker) dispatch(event *Event) error {
+	b.mu.Lock()
+	if b.closed.Load() {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	// 先登记，Shutdown 等待持久化中的发布完成
+	b.inflight.Add(1)
+	b.mu.Unlock()
+
+	b.replayGate.RLock()
+	defer b.replayGate.RUnlock()
+
+	if b.db != nil {
+		unlock := b.lockTopic(event)
+		defer unlock()
+		if err := b.persist(event); err != nil {
+			b.inflight.Add(-1)
+			b.logger.Error("Failed to persist event", zap.String("topic", event.Topic), zap.Error(err))
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resolveReply(event)
+
+	counters := b.counters(event)
+	counters.publish()
+
+	// 发布事件到通道，多数 Broker 不读取 Consume，其丢弃单独统计，不计入 Dropped 和 event.dropped
+	b.eventsCh.push(event)
+
+	// 分发事件给订阅者
+	tenant := tenantOf(event)
+	for topic, handlers := range b.topics {
+		if MatchTopic(topic, event.Topic) {
+			for _, h := range handlers {
+				if !h.scoped || h.tenant == tenant {
+					result := b.enqueue(h.outbox(event), "handler", event)
+					if result == pushed {
+						b.inflight.Add(1)
+					}
+					counters.count(result)
+				}
+			}
+		}
+	}
+
+	// 分发事件给同一租户的 WebSocket 和 SSE 客户端，队列溢出的慢客户端直接断开
+	for c := range b.clients {
+		if c.tenant != tenant || !c.matches(event.Topic) {
+			continue
+		}
+		result := b.enqueue(c.out, "client", event)
+		counters.count(result)
+		if result == overflowed {
+			b.logger.Warn("Disconnecting slow client", zap.String("remote", c.remote))
+			b.detachClient(c)
+			c.close()
+		}
 	}
 
 	return nil
 }
 
+// Subscribe 注册进程内订阅者，接收所有租户的事件，topic 支持通配符，匹配规则见 MatchTopic。
+// handler panic 时记录错误并继续处理后续事件，需要重试或死信时使用 SubscribeHandler
 func (b *Broker) Subscribe(topic string, handler func(*Event)) {
+	b.SubscribeHandler(topic, handlerFunc(handler))
+}
+
+// SubscribeTenant 注册只接收租户 tenant 事件的进程内订阅者，tenant 为空表示默认租户
+func (b *Broker) SubscribeTenant(tenant, topic string, handler func(*Event)) {
+	b.SubscribeHandler(topic, handlerFunc(handler), WithTenant(tenant))
+}
+
+// subscribe 注册进程内订阅者并启动其处理协程
+func (b *Broker) subscribe(h *listener) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.topics[topic] = append(b.topics[topic], handler)
+
+	if b.closing.Load() {
+		b.logger.Warn("Ignoring subscription on closed broker", zap.String("topic", h.topic))
+		return
+	}
+
+	// 进程内订阅者无法断开，DisconnectSlow 退化为丢弃新事件
+	opts := b.queueOptions
+	if opts.Policy == DisconnectSlow {
+		opts.Policy = DropNewest
+	}
+	partitions := max(h.partitions, 1)
+	h.outs = make([]*outbox, partitions)
+	for i := range h.outs {
+		h.outs[i] = newOutbox(opts)
+	}
+	b.topics[h.topic] = append(b.topics[h.topic], h)
+	b.wg.Add(partitions)
+	for _, out := range h.outs {
+		go b.runHandler(h, out)
+	}
 }
 
+// Consume 返回所有已发布事件的通道，未及时读取时丢弃最早的事件
 func (b *Broker) Consume() <-chan *Event {
-	return b.eventsCh
+	return b.eventsCh.ch
 }
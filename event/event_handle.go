@@ -1,16 +1,26 @@
 package event
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"log"
+	ws "github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 // handleSubscribe 订阅
 func (b *Broker) handleSubscribe(c *gin.Context) {
 	var subscribeRequest struct {
-		Topic  string `json:"topic"`
-		Client string `json:"client"`
+		Topic       string `json:"topic"`
+		Client      string `json:"client"`
+		CallbackUrl string `json:"callbackUrl"`
+		Secret      string `json:"secret"`
+		ContentType string `json:"contentType"`
+		Format      string `json:"format"`
 	}
 
 	if err := c.ShouldBindJSON(&subscribeRequest); err != nil {
@@ -18,94 +28,228 @@ func (b *Broker) handleSubscribe(c *gin.Context) {
 		return
 	}
 
+	if subscribeRequest.CallbackUrl != "" {
+		u, err := url.ParseRequestURI(subscribeRequest.CallbackUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid callbackUrl"})
+			return
+		}
+	}
+
+	if !b.authorize(c, ActionSubscribe, subscribeRequest.Topic) {
+		return
+	}
+
+	if !ValidFormat(subscribeRequest.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+
 	// 保存订阅信息到数据库
+	tenant := requestTenant(c)
 	subscription := Subscription{
-		Topic:  subscribeRequest.Topic,
-		Client: subscribeRequest.Client,
+		TenantId:    tenant,
+		Topic:       subscribeRequest.Topic,
+		Client:      subscribeRequest.Client,
+		CallbackUrl: subscribeRequest.CallbackUrl,
+		Secret:      subscribeRequest.Secret,
+		ContentType: subscribeRequest.ContentType,
+		Format:      subscribeRequest.Format,
 	}
 	if err := b.db.Create(&subscription).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save subscription"})
 		return
 	}
 
-	conn, err := upgrade.Upgrade(c.Writer, c.Request, nil)
+	// webhook 订阅由 Publish 推送，无需建立 WebSocket 连接
+	if subscription.CallbackUrl != "" {
+		c.JSON(http.StatusCreated, subscription)
+		return
+	}
+
+	if !b.acquireConn(c, tenant) {
+		return
+	}
+	conn, err := b.upgradeConn(c)
 	if err != nil {
+		b.tenants.releaseConn(tenant)
 		c.AbortWithStatus(http.StatusInternalServerError)
-		log.Println("Failed to upgrade connection:", err)
+		b.logger.Error("Failed to upgrade connection", zap.Error(err))
 		return
 	}
 
 	b.mu.Lock()
-	b.clients[conn] = subscribeRequest.Topic
+	cli := b.addClient(conn, c.ClientIP(), PrincipalFrom(c), tenant, subscribeRequest.Topic)
 	b.mu.Unlock()
 
-	go func() {
-		defer func() {
-			b.mu.Lock()
-			delete(b.clients, conn)
-			b.mu.Unlock()
-			err := conn.Close()
-			if err != nil {
-				return
-			}
-		}()
-
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
-		}
-	}()
+	b.serveClient(cli, nil)
 }
 
-// handlePublish 发布事件
+// handlePublish 发布事件，请求体可以是 Event JSON，也可以是 CloudEvents structured 或 binary 模式。
+// delay（如 "15m"）或 deliverAt（RFC 3339）参数指定延迟发布，需要配置数据库
 func (b *Broker) handlePublish(c *gin.Context) {
-	var event Event
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	deliverAt, err := scheduleTime(c.Query("delay"), c.Query("deliverAt"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delay or deliverAt"})
 		return
 	}
 
-	err := b.Publish(&event)
+	event, err := bindEvent(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish event"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if !b.checkPublisher(PrincipalFrom(c), requestTenant(c), event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": ActionPublish, "topic": event.Topic})
+		return
+	}
+
+	if deliverAt.After(time.Now()) {
+		if err = b.PublishAt(event, deliverAt); err != nil {
+			b.writePublishError(c, event, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Event scheduled", "event": event, "deliverAt": deliverAt})
+		return
+	}
+
+	if err = b.Publish(event); err != nil {
+		b.writePublishError(c, event, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Event published", "event": event})
 }
 
-// handleEvents 处理 WebSocket 连接
+// writePublishError 将发布失败的原因写入响应
+func (b *Broker) writePublishError(c *gin.Context, event *Event, err error) {
+	var schemaErr *SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "Event detail does not match topic schema",
+			"topic":      schemaErr.Topic,
+			"violations": schemaErr.Violations,
+		})
+	case errors.Is(err, ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Publish rate quota exceeded", "tenant": tenantOf(event)})
+	case errors.Is(err, ErrTopicNotFound):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Topic not registered", "topic": event.Topic})
+	case errors.Is(err, ErrTopicPaused):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Topic paused", "topic": event.Topic})
+	case errors.Is(err, ErrDuplicateEvent):
+		c.JSON(http.StatusConflict, gin.H{"error": "Duplicate event", "id": event.Id})
+	case errors.Is(err, ErrRequestPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Request already pending", "correlationId": event.Payload.CorrelationId})
+	case errors.Is(err, ErrNoDatabase):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish event"})
+	}
+}
+
+// bindEvent 按请求的内容类型解码事件
+func bindEvent(c *gin.Context) (*Event, error) {
+	switch {
+	case c.ContentType() == CloudEventsContentType:
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		return UnmarshalCloudEvent(body)
+	case IsBinaryCloudEvent(c.Request.Header):
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		return ReadBinaryCloudEvent(c.Request.Header, body)
+	default:
+		var event Event
+		if err := c.ShouldBindJSON(&event); err != nil {
+			return nil, err
+		}
+		return &event, nil
+	}
+}
+
+// handleEvents 处理 WebSocket 连接。
+// 可通过 topic 指定订阅主题（支持通配符），并通过 from_offset 先回放该主题中断期间错过的事件；
+// format=cloudevents 时未协商控制协议的连接以 CloudEvents structured JSON 接收事件
 func (b *Broker) handleEvents(c *gin.Context) {
-	conn, err := upgrade.Upgrade(c.Writer, c.Request, nil)
+	topic := c.Query("topic")
+	format := c.Query("format")
+	if format != FormatNative && format != FormatCloudEvents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+
+	// 控制协议下未指定 topic 的连接从空订阅开始，订阅时逐个授权
+	if (topic != "" || !b.wantsProtocol(c)) && !b.authorize(c, ActionSubscribe, topic) {
+		return
+	}
+
+	var fromOffset int64
+	replay := c.Query("from_offset") != ""
+	if replay {
+		if IsTopicPattern(topic) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_offset requires a concrete topic"})
+			return
+		}
+		offset, err := strconv.ParseInt(c.Query("from_offset"), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from_offset"})
+			return
+		}
+		fromOffset = offset
+	}
+
+	tenant := requestTenant(c)
+	if !b.acquireConn(c, tenant) {
+		return
+	}
+	conn, err := b.upgradeConn(c)
 	if err != nil {
+		b.tenants.releaseConn(tenant)
 		c.AbortWithStatus(http.StatusInternalServerError)
-		log.Println("Failed to upgrade connection:", err)
+		b.logger.Error("Failed to upgrade connection", zap.Error(err))
 		return
 	}
 
-	// 默认订阅所有主题
-	b.mu.Lock()
-	b.clients[conn] = ""
-	b.mu.Unlock()
+	// 未指定 topic 时默认订阅所有主题，控制协议下则从空订阅开始
+	var cli *client
+	if topic == "" && conn.Subprotocol() == ProtocolV1 {
+		cli = b.newClient(conn, c.ClientIP(), PrincipalFrom(c), tenant)
+	} else {
+		cli = b.newClient(conn, c.ClientIP(), PrincipalFrom(c), tenant, topic)
+	}
+	cli.format = format
 
-	go func() {
-		defer func() {
-			b.mu.Lock()
-			delete(b.clients, conn)
-			b.mu.Unlock()
-			err := conn.Close()
-			if err != nil {
-				return
-			}
-		}()
-
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
+	register := func() { b.registerClient(cli) }
+	if !replay {
+		b.mu.Lock()
+		register()
+		b.mu.Unlock()
+		b.serveClient(cli, nil)
+		return
+	}
+
+	// 分页回放中断期间错过的事件，追上后注册，回放期间发布的事件在注册后推送
+	fetch := func(limit int) ([]*Event, bool, error) {
+		events, err := b.ReplayTenant(tenant, topic, fromOffset, limit)
+		if len(events) > 0 {
+			fromOffset = events[len(events)-1].Offset + 1
 		}
-	}()
+		return events, limit > 0 && len(events) == limit, err
+	}
+	write := func(event *Event) error { return cli.write(event) }
+	events, err := b.catchUp(fetch, write, register)
+	if err != nil {
+		b.tenants.releaseConn(tenant)
+		b.logger.Error("Failed to replay events", zap.String("topic", topic), zap.Error(err))
+		_ = conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseInternalServerErr, "replay failed"))
+		_ = conn.Close()
+		return
+	}
+	b.serveClient(cli, events)
 }
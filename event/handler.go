@@ -0,0 +1,176 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// DeadLetterEventType 死信事件的 EventType
+const DeadLetterEventType = "event.deadletter"
+
+// Handler 返回错误的进程内订阅者，返回错误或 panic 时按订阅的重试策略重试
+type Handler func(*Event) error
+
+// SubscribeOption SubscribeHandler 的配置项
+type SubscribeOption func(*listener)
+
+// DeadLetterDetail 死信事件的 Detail，记录处理失败的原事件
+type DeadLetterDetail struct {
+	Topic    string `json:"topic"`
+	EventId  string `json:"eventId,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+	Event    *Event `json:"event"`
+}
+
+// PanicError 订阅者 panic 时的错误
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("event: handler panic: %v", e.Value)
+}
+
+// WithRetry 设置处理失败时的重试策略，默认不重试。未开启 WithOrderedBy 时等待重试的事件
+// 不阻塞后续事件，因此订阅者可能被并发调用，事件的处理顺序也不再与发布顺序一致
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(h *listener) {
+		h.retry = policy
+	}
+}
+
+// WithDeadLetterTopic 重试耗尽后将事件以 DeadLetterDetail 发布到 topic，默认只记录日志
+func WithDeadLetterTopic(topic string) SubscribeOption {
+	return func(h *listener) {
+		h.deadLetter = topic
+	}
+}
+
+// WithTenant 只接收租户 tenant 的事件，tenant 为空表示默认租户
+func WithTenant(tenant string) SubscribeOption {
+	return func(h *listener) {
+		h.tenant = tenant
+		h.scoped = true
+	}
+}
+
+// SubscribeHandler 注册返回错误的进程内订阅者，topic 支持通配符，匹配规则见 MatchTopic
+func (b *Broker) SubscribeHandler(topic string, handler Handler, opts ...SubscribeOption) {
+	h := &listener{topic: topic, fn: handler, retry: RetryPolicy{MaxAttempts: 1}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	b.subscribe(h)
+}
+
+// handlerFunc 将不返回错误的订阅者转换为 Handler
+func handlerFunc(handler func(*Event)) Handler {
+	return func(event *Event) error {
+		handler(event)
+		return nil
+	}
+}
+
+// handle 调用订阅者处理事件，失败时按重试策略重试，重试耗尽后转入死信主题
+func (b *Broker) handle(h *listener, event *Event) {
+	b.attempt(h, event, 1)
+}
+
+// attempt 从第 attempt 次开始处理事件。有序订阅者在处理协程中等待重试以保证分区内的顺序；
+// 其他订阅者在独立协程中等待，不阻塞后续事件，等待重试的事件超过队列容量时同样在处理协程中等待
+func (b *Broker) attempt(h *listener, event *Event, attempt int) {
+	for {
+		err := h.call(event)
+		if err == nil {
+			return
+		}
+		b.logger.Warn("Event handler failed",
+			zap.String("topic", event.Topic),
+			zap.String("id", event.Id),
+			zap.Int("attempt", attempt),
+			zap.Int("maxAttempts", h.retry.MaxAttempts),
+			zap.Error(err))
+		if attempt >= h.retry.MaxAttempts {
+			b.giveUp(h, event, attempt, err)
+			return
+		}
+
+		if h.key == nil && b.retryLater(h, event, attempt, err) {
+			return
+		}
+		// 关闭时不再重试
+		if !b.sleep(h.retry.Backoff(attempt)) {
+			b.giveUp(h, event, attempt, err)
+			return
+		}
+		attempt++
+	}
+}
+
+// retryLater 在独立协程中等待退避后重试第 attempt 次失败的事件，等待重试的事件已达队列容量时返回 false
+func (b *Broker) retryLater(h *listener, event *Event, attempt int, err error) bool {
+	if h.retrying.Add(1) > int32(cap(h.outs[0].ch)) {
+		h.retrying.Add(-1)
+		return false
+	}
+
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Add(-1)
+		defer h.retrying.Add(-1)
+		if !b.sleep(h.retry.Backoff(attempt)) {
+			b.giveUp(h, event, attempt, err)
+			return
+		}
+		b.attempt(h, event, attempt+1)
+	}()
+	return true
+}
+
+// giveUp 记录重试耗尽的事件，配置了死信主题时转发
+func (b *Broker) giveUp(h *listener, event *Event, attempts int, err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		b.logger.Error("Event handler panicked", zap.String("topic", event.Topic), zap.ByteString("stack", panicErr.Stack))
+	}
+	b.recordError("handle", event, err)
+
+	// 死信事件本身处理失败时不再转发，避免循环
+	if h.deadLetter == "" || event.EventType == DeadLetterEventType {
+		return
+	}
+	deadLetter := &Event{
+		Topic:     h.deadLetter,
+		Subject:   event.Topic,
+		EventType: DeadLetterEventType,
+		EventTime: event.EventTime,
+		Detail: DeadLetterDetail{
+			Topic:    event.Topic,
+			EventId:  event.Id,
+			Attempts: attempts,
+			Error:    err.Error(),
+			Event:    event,
+		},
+	}
+	if event.Payload != nil {
+		deadLetter.Payload = &Payload{TenantId: event.Payload.TenantId, CorrelationId: event.Payload.CorrelationId}
+	}
+	if err := b.publishEvent(deadLetter); err != nil {
+		b.logger.Error("Failed to publish dead letter event", zap.String("topic", h.deadLetter), zap.String("id", event.Id), zap.Error(err))
+	}
+}
+
+// call 调用订阅者，panic 转换为 PanicError
+func (h *listener) call(event *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return h.fn(event)
+}
@@ -0,0 +1,97 @@
+package event
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_SubscribeHandler(t *testing.T) {
+	b := NewBroker()
+
+	deadLetters := make(chan *Event, 1)
+	b.Subscribe("orders.dlq", func(e *Event) { deadLetters <- e })
+
+	var attempts int
+	b.SubscribeHandler("orders", func(*Event) error {
+		attempts++
+		if attempts == 1 {
+			panic("boom")
+		}
+		return errors.New("still failing")
+	}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}), WithDeadLetterTopic("orders.dlq"))
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "e1", Payload: &Payload{TenantId: "t1"}}))
+
+	select {
+	case e := <-deadLetters:
+		require.Equal(t, DeadLetterEventType, e.EventType)
+		require.Equal(t, "t1", e.Payload.TenantId)
+		detail := e.Detail.(DeadLetterDetail)
+		require.Equal(t, "e1", detail.EventId)
+		require.Equal(t, 3, detail.Attempts)
+		require.Equal(t, "still failing", detail.Error)
+	case <-time.After(time.Second):
+		t.Fatal("dead letter event was not published")
+	}
+	require.Equal(t, 3, attempts)
+
+	errs := b.errors.list()
+	require.Len(t, errs, 1)
+	require.Equal(t, "handle", errs[0].Op)
+}
+
+func TestBroker_SubscribePanicIsolation(t *testing.T) {
+	b := NewBroker()
+
+	got := make(chan string, 2)
+	b.Subscribe("orders", func(e *Event) {
+		if e.Id == "bad" {
+			panic("boom")
+		}
+		got <- e.Id
+	})
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "bad"}))
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "good"}))
+
+	select {
+	case id := <-got:
+		require.Equal(t, "good", id)
+	case <-time.After(time.Second):
+		t.Fatal("handler stopped after panic")
+	}
+	var panicErr *PanicError
+	require.ErrorAs(t, (&listener{fn: func(*Event) error { panic("boom") }}).call(&Event{}), &panicErr)
+	require.Equal(t, "boom", panicErr.Value)
+}
+
+func TestBroker_RetryDoesNotBlock(t *testing.T) {
+	b := NewBroker()
+
+	got := make(chan string, 3)
+	var failed atomic.Bool
+	b.SubscribeHandler("orders", func(e *Event) error {
+		if e.Id == "flaky" && !failed.Swap(true) {
+			return errors.New("try again")
+		}
+		got <- e.Id
+		return nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: 100 * time.Millisecond}))
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "flaky"}))
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "next"}))
+
+	// 等待重试期间继续处理后续事件
+	for _, want := range []string{"next", "flaky"} {
+		select {
+		case id := <-got:
+			require.Equal(t, want, id)
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not handled", want)
+		}
+	}
+}
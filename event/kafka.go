@@ -0,0 +1,407 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+// Kafka 消息头，与 CloudEvents Kafka 协议绑定的属性命名保持一致
+const (
+	kafkaHeaderId            = "ce_id"
+	kafkaHeaderType          = "ce_type"
+	kafkaHeaderSubject       = "ce_subject"
+	kafkaHeaderTopic         = "ce_topic"
+	kafkaHeaderCorrelationId = "ce_correlationid"
+	kafkaHeaderTenantId      = "ce_tenantid"
+)
+
+// ErrKafkaNoConsumer Kafka 传输未配置消费者组
+var ErrKafkaNoConsumer = errors.New("event: kafka consumer group not configured")
+
+// kafkaConsumeBackoff 加入消费者组失败（如 Kafka 不可用、主题不存在）后重试的等待时间
+var kafkaConsumeBackoff = RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// KafkaConfig Kafka 传输配置
+type KafkaConfig struct {
+	Brokers     []string `toml:"brokers"`
+	GroupId     string   `toml:"groupId"`     // 消费者组，为空时只发布不消费
+	TopicPrefix string   `toml:"topicPrefix"` // 事件主题映射为 Kafka 主题时添加的前缀
+	Version     string   `toml:"version"`     // Kafka 版本，如 "3.6.0"，为空时使用 sarama 默认值
+}
+
+// TopicMapper 将事件主题映射为 Kafka 主题
+type TopicMapper func(topic string) string
+
+// PrefixTopicMapper 返回为事件主题添加前缀的映射
+func PrefixTopicMapper(prefix string) TopicMapper {
+	return func(topic string) string {
+		return prefix + topic
+	}
+}
+
+// Kafka 基于 Kafka 的 Publisher、Subscriber 和 Consumer 实现。
+// 事件以 JSON 写入消息体，CorrelationId 作为消息 key 保证同一关联链路落在同一分区，
+// 事件的元数据同时写入消息头，便于不解码消息体的下游路由；消费时消息体中缺少的元数据从消息头补全。
+// Payload.Authorization 是发布方的凭证，不写入 Kafka。
+type Kafka struct {
+	producer sarama.SyncProducer
+	group    sarama.ConsumerGroup
+	mapTopic TopicMapper
+	logger   *zap.Logger
+	backoff  RetryPolicy // 加入消费者组失败后的重试等待
+
+	mu       sync.RWMutex
+	handlers map[string][]func(*Event) // 事件主题到订阅者，由 mu 保护
+	rejoin   context.CancelFunc        // 结束当前消费会话以重新加入消费者组，由 mu 保护
+	changed  chan struct{}             // 订阅的主题发生变化
+	eventsCh *outbox
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewKafka 根据配置创建 Kafka 传输
+func NewKafka(cfg *KafkaConfig, logger *zap.Logger) (*Kafka, error) {
+	conf := sarama.NewConfig()
+	conf.Producer.Return.Successes = true
+	conf.Producer.RequiredAcks = sarama.WaitForAll
+	conf.Consumer.Return.Errors = true
+	conf.Consumer.Offsets.Initial = sarama.OffsetOldest
+	if cfg.Version != "" {
+		version, err := sarama.ParseKafkaVersion(cfg.Version)
+		if err != nil {
+			return nil, err
+		}
+		conf.Version = version
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var group sarama.ConsumerGroup
+	if cfg.GroupId != "" {
+		group, err = sarama.NewConsumerGroup(cfg.Brokers, cfg.GroupId, conf)
+		if err != nil {
+			_ = producer.Close()
+			return nil, err
+		}
+	}
+
+	return NewKafkaWithClients(producer, group, PrefixTopicMapper(cfg.TopicPrefix), logger), nil
+}
+
+// NewKafkaWithClients 使用已创建的生产者和消费者组创建 Kafka 传输，group 可为 nil
+func NewKafkaWithClients(producer sarama.SyncProducer, group sarama.ConsumerGroup, mapTopic TopicMapper, logger *zap.Logger) *Kafka {
+	if mapTopic == nil {
+		mapTopic = PrefixTopicMapper("")
+	}
+	if logger == nil {
+		logger = zap.L()
+	}
+	return &Kafka{
+		producer: producer,
+		group:    group,
+		mapTopic: mapTopic,
+		logger:   logger,
+		backoff:  kafkaConsumeBackoff,
+		handlers: make(map[string][]func(*Event)),
+		changed:  make(chan struct{}, 1),
+		eventsCh: newOutbox(DefaultQueueOptions),
+	}
+}
+
+// Publish 将事件同步写入映射后的 Kafka 主题
+func (k *Kafka) Publish(event *Event) error {
+	msg, err := k.encode(event)
+	if err != nil {
+		return err
+	}
+	_, _, err = k.producer.SendMessage(msg)
+	return err
+}
+
+// encode 将事件编码为 Kafka 消息，去掉 Payload.Authorization
+func (k *Kafka) encode(event *Event) (*sarama.ProducerMessage, error) {
+	event = withoutCredentials(event)
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: k.mapTopic(event.Topic),
+		Value: sarama.ByteEncoder(data),
+	}
+
+	headers := map[string]string{
+		kafkaHeaderId:      event.Id,
+		kafkaHeaderType:    event.EventType,
+		kafkaHeaderSubject: event.Subject,
+		kafkaHeaderTopic:   event.Topic,
+	}
+	if p := event.Payload; p != nil {
+		headers[kafkaHeaderCorrelationId] = p.CorrelationId
+		headers[kafkaHeaderTenantId] = p.TenantId
+		if p.CorrelationId != "" {
+			msg.Key = sarama.StringEncoder(p.CorrelationId)
+		}
+	}
+	for key, value := range headers {
+		if value != "" {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+		}
+	}
+
+	return msg, nil
+}
+
+// Subscribe 注册事件主题的订阅者。topic 映射后作为 Kafka 主题订阅，因此必须是具体主题，
+// 通配模式无法映射为 Kafka 主题，记录错误后忽略；
+// Start 之后订阅新主题会结束当前会话并重新加入消费者组
+func (k *Kafka) Subscribe(topic string, handler func(*Event)) {
+	if IsTopicPattern(topic) {
+		k.logger.Error("Kafka transport does not support topic patterns", zap.String("topic", topic))
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	_, exists := k.handlers[topic]
+	k.handlers[topic] = append(k.handlers[topic], handler)
+	if exists {
+		return
+	}
+	if k.rejoin != nil {
+		k.rejoin()
+	}
+	select {
+	case k.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Consume 返回所有已消费事件的通道，未及时读取时丢弃最早的事件，Close 后关闭
+func (k *Kafka) Consume() <-chan *Event {
+	return k.eventsCh.ch
+}
+
+// Start 在后台加入消费者组并开始消费已订阅的主题
+func (k *Kafka) Start(ctx context.Context) error {
+	if k.group == nil {
+		return ErrKafkaNoConsumer
+	}
+
+	ctx, k.cancel = context.WithCancel(ctx)
+	k.wg.Add(2)
+	go func() {
+		defer k.wg.Done()
+		k.consumeLoop(ctx)
+	}()
+	go func() {
+		defer k.wg.Done()
+		for {
+			select {
+			case err, ok := <-k.group.Errors():
+				if !ok {
+					return
+				}
+				k.logger.Error("Kafka consumer error", zap.Error(err))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// consumeLoop 加入消费者组并消费，再均衡或订阅的主题变化后重新读取主题并再次加入，
+// 加入失败时按 k.backoff 等待后重试
+func (k *Kafka) consumeLoop(ctx context.Context) {
+	failures := 0
+	for {
+		topics := k.kafkaTopics()
+		if len(topics) == 0 {
+			select {
+			case <-k.changed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		session, cancel := context.WithCancel(ctx)
+		k.mu.Lock()
+		k.rejoin = cancel
+		k.mu.Unlock()
+
+		err := k.group.Consume(session, topics, k)
+		cancel()
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) || ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		failures++
+		wait := k.backoff.Backoff(failures)
+		k.logger.Error("Kafka consume failed", zap.Int("failures", failures), zap.Duration("retryIn", wait), zap.Error(err))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// kafkaTopics 返回订阅的事件主题映射后的 Kafka 主题
+func (k *Kafka) kafkaTopics() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	topics := make([]string, 0, len(k.handlers))
+	for topic := range k.handlers {
+		topics = append(topics, k.mapTopic(topic))
+	}
+	return topics
+}
+
+// Close 停止消费并关闭生产者和消费者组
+func (k *Kafka) Close() error {
+	if k.cancel != nil {
+		k.cancel()
+	}
+
+	var errs []error
+	if k.group != nil {
+		errs = append(errs, k.group.Close())
+	}
+	k.wg.Wait()
+	errs = append(errs, k.producer.Close())
+	k.eventsCh.close()
+	return errors.Join(errs...)
+}
+
+// Shutdown 在 ctx 结束前关闭 Kafka 传输，见 Close
+func (k *Kafka) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- k.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Setup 实现 sarama.ConsumerGroupHandler
+func (k *Kafka) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup 实现 sarama.ConsumerGroupHandler
+func (k *Kafka) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim 实现 sarama.ConsumerGroupHandler，按分区顺序同步调用订阅者后提交 offset
+func (k *Kafka) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			var event Event
+			if err := json.Unmarshal(msg.Value, &event); err != nil {
+				k.logger.Warn("Failed to decode Kafka message",
+					zap.String("topic", msg.Topic),
+					zap.Int32("partition", msg.Partition),
+					zap.Int64("offset", msg.Offset),
+					zap.Error(err))
+				session.MarkMessage(msg, "")
+				continue
+			}
+			applyKafkaHeaders(&event, msg.Headers)
+			k.dispatch(&event)
+			k.eventsCh.push(&event)
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// applyKafkaHeaders 用消息头补全消息体中缺少的元数据
+func applyKafkaHeaders(event *Event, recordHeaders []*sarama.RecordHeader) {
+	headers := make(map[string]string, len(recordHeaders))
+	for _, h := range recordHeaders {
+		if h != nil {
+			headers[string(h.Key)] = string(h.Value)
+		}
+	}
+
+	setIfEmpty(&event.Id, headers[kafkaHeaderId])
+	setIfEmpty(&event.EventType, headers[kafkaHeaderType])
+	setIfEmpty(&event.Subject, headers[kafkaHeaderSubject])
+	setIfEmpty(&event.Topic, headers[kafkaHeaderTopic])
+	if headers[kafkaHeaderCorrelationId] == "" && headers[kafkaHeaderTenantId] == "" {
+		return
+	}
+	if event.Payload == nil {
+		event.Payload = &Payload{}
+	}
+	setIfEmpty(&event.Payload.CorrelationId, headers[kafkaHeaderCorrelationId])
+	setIfEmpty(&event.Payload.TenantId, headers[kafkaHeaderTenantId])
+}
+
+// setIfEmpty *dst 为空时设置为 value
+func setIfEmpty(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// dispatch 调用匹配事件主题的订阅者。订阅者在锁外调用，可以在处理事件时订阅新主题；
+// 订阅者 panic 时记录错误并继续调用其他订阅者
+func (k *Kafka) dispatch(event *Event) {
+	var matched []func(*Event)
+	k.mu.RLock()
+	for topic, handlers := range k.handlers {
+		if MatchTopic(topic, event.Topic) {
+			matched = append(matched, handlers...)
+		}
+	}
+	k.mu.RUnlock()
+
+	for _, handler := range matched {
+		k.call(handler, event)
+	}
+}
+
+// call 调用订阅者，recover 其 panic
+func (k *Kafka) call(handler func(*Event), event *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			k.logger.Error("Kafka event handler panicked",
+				zap.String("topic", event.Topic),
+				zap.String("id", event.Id),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+		}
+	}()
+	handler(event)
+}
@@ -0,0 +1,167 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestKafka_Publish(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		require.Equal(t, "app.orders.created", msg.Topic)
+
+		key, err := msg.Key.Encode()
+		require.NoError(t, err)
+		require.Equal(t, "c1", string(key))
+
+		headers := map[string]string{}
+		for _, h := range msg.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		require.Equal(t, map[string]string{
+			kafkaHeaderId:            "e1",
+			kafkaHeaderTopic:         "orders.created",
+			kafkaHeaderCorrelationId: "c1",
+		}, headers)
+
+		// 发布方的凭证不写入 Kafka
+		value, err := msg.Value.Encode()
+		require.NoError(t, err)
+		require.NotContains(t, string(value), "secret")
+		return nil
+	})
+
+	k := NewKafkaWithClients(producer, nil, PrefixTopicMapper("app."), nil)
+	event := &Event{Topic: "orders.created", Id: "e1", Payload: &Payload{CorrelationId: "c1", Authorization: "Bearer secret"}}
+	require.NoError(t, k.Publish(event))
+	require.Equal(t, "Bearer secret", event.Payload.Authorization)
+	require.ErrorIs(t, k.Start(context.Background()), ErrKafkaNoConsumer)
+	require.NoError(t, k.Close())
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestKafka_ConsumeClaim(t *testing.T) {
+	k := NewKafkaWithClients(mocks.NewSyncProducer(t, nil), nil, nil, nil)
+
+	var handled []*Event
+	k.Subscribe("orders.created", func(e *Event) { handled = append(handled, e) })
+
+	data, err := json.Marshal(&Event{Topic: "orders.created", Id: "e1"})
+	require.NoError(t, err)
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.created", Offset: 1, Value: []byte("not json")}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.created", Offset: 2, Value: data, Headers: []*sarama.RecordHeader{
+		{Key: []byte(kafkaHeaderId), Value: []byte("ignored")},
+		{Key: []byte(kafkaHeaderTenantId), Value: []byte("acme")},
+	}}
+	close(claim.messages)
+
+	session := &fakeSession{ctx: context.Background()}
+	require.NoError(t, k.ConsumeClaim(session, claim))
+
+	require.Equal(t, []int64{1, 2}, session.marked)
+	require.Len(t, handled, 1)
+	// 消息体中已有的元数据优先，缺少的从消息头补全
+	require.Equal(t, "e1", handled[0].Id)
+	require.Equal(t, "acme", handled[0].Payload.TenantId)
+	require.Equal(t, "e1", (<-k.Consume()).Id)
+}
+
+func TestKafka_DispatchPanic(t *testing.T) {
+	k := NewKafkaWithClients(mocks.NewSyncProducer(t, nil), nil, nil, zap.NewNop())
+
+	var handled []string
+	k.Subscribe("orders.created", func(*Event) { panic("boom") })
+	k.Subscribe("orders.created", func(e *Event) {
+		handled = append(handled, e.Id)
+		// 订阅者在锁外调用，处理时可以订阅新主题
+		k.Subscribe("orders.updated", func(*Event) {})
+	})
+
+	k.dispatch(&Event{Topic: "orders.created", Id: "e1"})
+	require.Equal(t, []string{"e1"}, handled)
+	require.Len(t, k.kafkaTopics(), 2)
+}
+
+// failingGroup 每次加入都失败的消费者组
+type failingGroup struct {
+	sarama.ConsumerGroup
+	calls atomic.Int32
+}
+
+func (g *failingGroup) Consume(context.Context, []string, sarama.ConsumerGroupHandler) error {
+	g.calls.Add(1)
+	return errors.New("unknown topic or partition")
+}
+
+func TestKafka_ConsumeBackoff(t *testing.T) {
+	group := &failingGroup{}
+	k := NewKafkaWithClients(mocks.NewSyncProducer(t, nil), group, nil, zap.NewNop())
+	k.backoff = RetryPolicy{InitialBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	k.Subscribe("orders.created", func(*Event) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		k.consumeLoop(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	// 失败后等待再重试，而不是立即重试
+	require.Positive(t, group.calls.Load())
+	require.LessOrEqual(t, group.calls.Load(), int32(4))
+}
+
+func TestKafka_SubscribePattern(t *testing.T) {
+	k := NewKafkaWithClients(mocks.NewSyncProducer(t, nil), nil, nil, zap.NewNop())
+	k.Subscribe("orders.#", func(*Event) {})
+	k.Subscribe("orders.*", func(*Event) {})
+	require.Empty(t, k.kafkaTopics())
+
+	k.Subscribe("orders.created", func(*Event) {})
+	require.Equal(t, []string{"orders.created"}, k.kafkaTopics())
+}
+
+func TestNewTransport(t *testing.T) {
+	logger := zap.NewNop()
+	transport, err := NewTransport(&TransportConfig{}, logger, WithBufferSize(8))
+	require.NoError(t, err)
+	b := transport.(*Broker)
+	require.Same(t, logger, b.logger)
+	require.Equal(t, 8, b.queueOptions.Size)
+
+	_, err = NewTransport(&TransportConfig{Type: TransportKafka}, logger)
+	require.Error(t, err)
+	_, err = NewTransport(&TransportConfig{Type: "nats"}, nil)
+	require.Error(t, err)
+}
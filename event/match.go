@@ -0,0 +1,98 @@
+package event
+
+import "strings"
+
+const (
+	// topicSeparator 主题分段分隔符
+	topicSeparator = "."
+	// singleWildcard 匹配恰好一个分段
+	singleWildcard = "*"
+	// multiWildcard 匹配零个或多个分段
+	multiWildcard = "#"
+)
+
+// MatchTopic 判断主题是否匹配订阅模式。
+// 模式按 "." 分段，"*" 匹配恰好一段，"#" 匹配零或多段，例如 "orders.*.created"、"orders.#"。
+// 空模式、"#" 以及兼容旧订阅的单独 "*" 匹配所有主题。
+func MatchTopic(pattern, topic string) bool {
+	if pattern == "" || pattern == multiWildcard || pattern == singleWildcard {
+		return true
+	}
+	if !IsTopicPattern(pattern) {
+		return pattern == topic
+	}
+	return matchSegments(strings.Split(pattern, topicSeparator), strings.Split(topic, topicSeparator))
+}
+
+// IsTopicPattern 判断订阅主题是否包含通配符
+func IsTopicPattern(pattern string) bool {
+	return pattern == "" || strings.Contains(pattern, singleWildcard) || strings.Contains(pattern, multiWildcard)
+}
+
+func matchSegments(pattern, topic []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case multiWildcard:
+			// 连续的 "#" 等价于一个
+			rest := pattern[1:]
+			for len(rest) > 0 && rest[0] == multiWildcard {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(topic); i++ {
+				if matchSegments(rest, topic[i:]) {
+					return true
+				}
+			}
+			return false
+		case singleWildcard:
+			if len(topic) == 0 {
+				return false
+			}
+		default:
+			if len(topic) == 0 || pattern[0] != topic[0] {
+				return false
+			}
+		}
+		pattern, topic = pattern[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
+
+// CoversTopic 判断授权模式 pattern 是否覆盖订阅模式 requested 可能匹配的所有主题。
+// 与 MatchTopic 不同，requested 中的通配符只能被同样宽或更宽的通配符覆盖。
+func CoversTopic(pattern, requested string) bool {
+	if pattern == "" || pattern == singleWildcard {
+		pattern = multiWildcard
+	}
+	if requested == "" || requested == singleWildcard {
+		requested = multiWildcard
+	}
+	return coverSegments(strings.Split(pattern, topicSeparator), strings.Split(requested, topicSeparator))
+}
+
+func coverSegments(pattern, requested []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case multiWildcard:
+			for i := 0; i <= len(requested); i++ {
+				if coverSegments(pattern[1:], requested[i:]) {
+					return true
+				}
+			}
+			return false
+		case singleWildcard:
+			if len(requested) == 0 || requested[0] == multiWildcard {
+				return false
+			}
+		default:
+			if len(requested) == 0 || pattern[0] != requested[0] {
+				return false
+			}
+		}
+		pattern, requested = pattern[1:], requested[1:]
+	}
+	return len(requested) == 0
+}
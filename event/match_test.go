@@ -0,0 +1,60 @@
+package event
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"", "orders.created", true},
+		{"#", "orders.created", true},
+		{"*", "orders.created", true},
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.updated", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.*.created", "orders.created", false},
+		{"orders.*.created", "orders.eu.west.created", false},
+		{"orders.#", "orders", true},
+		{"orders.#", "orders.eu.created", true},
+		{"orders.#", "users.created", false},
+		{"orders.#.created", "orders.created", true},
+		{"orders.#.created", "orders.eu.west.created", true},
+		{"orders.#.created", "orders.eu.updated", false},
+		{"*.#", "orders", true},
+		{"orders.*", "orders", false},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.want, MatchTopic(c.pattern, c.topic), "pattern=%q topic=%q", c.pattern, c.topic)
+	}
+}
+
+func TestCoversTopic(t *testing.T) {
+	cases := []struct {
+		pattern   string
+		requested string
+		want      bool
+	}{
+		{"", "orders.#", true},
+		{"#", "", true},
+		{"orders.#", "orders.created", true},
+		{"orders.#", "orders.*.created", true},
+		{"orders.#", "orders.#", true},
+		{"orders.#", "#", false},
+		{"orders.#", "", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.*", true},
+		{"orders.*", "orders.#", false},
+		{"orders.created", "orders.*", false},
+		{"orders.*.created", "orders.eu.created", true},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.want, CoversTopic(c.pattern, c.requested), "pattern=%q requested=%q", c.pattern, c.requested)
+	}
+}
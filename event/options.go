@@ -0,0 +1,123 @@
+package event
+
+import (
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Option Broker 配置项
+type Option func(*Broker)
+
+// WithDB 设置持久化事件、订阅和主题使用的数据库
+func WithDB(db *gorm.DB) Option {
+	return func(b *Broker) {
+		b.db = db
+	}
+}
+
+// WithLogger 设置日志，默认使用 zap.L()
+func WithLogger(logger *zap.Logger) Option {
+	return func(b *Broker) {
+		if logger != nil {
+			b.logger = logger
+		}
+	}
+}
+
+// WithUpgrader 设置 WebSocket 升级器，可用于限制 CheckOrigin
+func WithUpgrader(upgrader *ws.Upgrader) Option {
+	return func(b *Broker) {
+		if upgrader != nil {
+			b.upgrader = upgrader
+		}
+	}
+}
+
+// WithBufferSize 设置每个订阅者出站队列以及 Consume 通道的容量
+func WithBufferSize(size int) Option {
+	return func(b *Broker) {
+		if size > 0 {
+			b.queueOptions.Size = size
+		}
+	}
+}
+
+// WithOverflowPolicy 设置订阅者队列已满时的处理策略
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(b *Broker) {
+		b.queueOptions.Policy = policy
+	}
+}
+
+// WithRetryPolicy 设置 webhook 推送的重试策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(b *Broker) {
+		b.retryPolicy = policy
+	}
+}
+
+// WithAuth 设置 HTTP 接口的认证方式
+func WithAuth(auth Authenticator) Option {
+	return func(b *Broker) {
+		b.auth = auth
+	}
+}
+
+// WithAuthorizer 设置发布、订阅和主题管理的授权方式，通常与 WithAuth 一起使用
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(b *Broker) {
+		b.authorizer = authorizer
+	}
+}
+
+// WithHeartbeatInterval 设置 SSE 心跳注释的发送间隔
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(b *Broker) {
+		if interval > 0 {
+			b.heartbeatInterval = interval
+		}
+	}
+}
+
+// WithScheduleInterval 设置检查到期定时事件的间隔
+func WithScheduleInterval(interval time.Duration) Option {
+	return func(b *Broker) {
+		if interval > 0 {
+			b.scheduleInterval = interval
+		}
+	}
+}
+
+// WithDedupWindow 对 window 内 Id 重复的发布去重，mode 指定忽略还是拒绝重复事件
+func WithDedupWindow(window time.Duration, mode DedupMode) Option {
+	return func(b *Broker) {
+		if window > 0 {
+			b.dedup = NewDeduplicator(window)
+			b.dedupMode = mode
+		}
+	}
+}
+
+// WithRequireTopic 要求事件主题已通过 /topic 接口注册，否则拒绝发布，需要同时配置数据库
+func WithRequireTopic() Option {
+	return func(b *Broker) {
+		b.requireTopic = true
+	}
+}
+
+// WithTenantQuota 设置租户的默认配额
+func WithTenantQuota(quota TenantQuota) Option {
+	return func(b *Broker) {
+		b.tenants.quota = quota
+	}
+}
+
+// WithTenantQuotas 为指定租户单独设置配额，未配置的租户使用 WithTenantQuota 设置的默认配额
+func WithTenantQuotas(quotas map[string]TenantQuota) Option {
+	return func(b *Broker) {
+		b.tenants.quotas = quotas
+	}
+}
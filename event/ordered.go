@@ -0,0 +1,60 @@
+package event
+
+import "hash/fnv"
+
+// DefaultPartitions WithOrderedBy 未指定分区数时的并发处理数
+const DefaultPartitions = 8
+
+// KeyFunc 提取事件的分区键，键相同的事件按发布顺序依次处理
+type KeyFunc func(*Event) string
+
+// KeyByResourceUri 以 Payload.ResourceUri 作为分区键
+func KeyByResourceUri(event *Event) string {
+	if event.Payload == nil {
+		return ""
+	}
+	return event.Payload.ResourceUri
+}
+
+// KeyByCorrelationId 以 Payload.CorrelationId 作为分区键
+func KeyByCorrelationId(event *Event) string {
+	if event.Payload == nil {
+		return ""
+	}
+	return event.Payload.CorrelationId
+}
+
+// KeyBySubject 以 Subject 作为分区键
+func KeyBySubject(event *Event) string {
+	return event.Subject
+}
+
+// WithOrderedBy 按 key 将事件分配到 partitions 个处理协程：键相同的事件按发布顺序依次处理，
+// 键不同的事件并行处理。键为空的事件按 Id 分配，不保证顺序；key 为 nil 时所有事件均按 Id 分配，
+// 即只并行处理而不保证顺序。partitions <= 0 时使用 DefaultPartitions
+func WithOrderedBy(key KeyFunc, partitions int) SubscribeOption {
+	return func(h *listener) {
+		if partitions <= 0 {
+			partitions = DefaultPartitions
+		}
+		h.key = key
+		h.partitions = partitions
+	}
+}
+
+// outbox 返回事件所在分区的队列
+func (h *listener) outbox(event *Event) *outbox {
+	if len(h.outs) == 1 {
+		return h.outs[0]
+	}
+	var key string
+	if h.key != nil {
+		key = h.key(event)
+	}
+	if key == "" {
+		key = event.Id
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return h.outs[hash.Sum32()%uint32(len(h.outs))]
+}
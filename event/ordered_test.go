@@ -0,0 +1,77 @@
+package event
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_WithOrderedBy(t *testing.T) {
+	b := NewBroker()
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+		wg   sync.WaitGroup
+	)
+	release := make(chan struct{})
+	b.SubscribeHandler("orders", func(e *Event) error {
+		defer wg.Done()
+		key := KeyByResourceUri(e)
+		if key == "slow" {
+			<-release
+		}
+		n, _ := strconv.Atoi(e.Id)
+		mu.Lock()
+		seen[key] = append(seen[key], n)
+		mu.Unlock()
+		return nil
+	}, WithOrderedBy(KeyByResourceUri, 4))
+
+	// 选择与 slow 不在同一分区的键，验证不同键并行处理
+	var fast string
+	for i := 0; fast == ""; i++ {
+		key := "fast" + strconv.Itoa(i)
+		h := b.topics["orders"][0]
+		if h.outbox(&Event{Payload: &Payload{ResourceUri: key}}) != h.outbox(&Event{Payload: &Payload{ResourceUri: "slow"}}) {
+			fast = key
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, key := range []string{"slow", fast} {
+			wg.Add(1)
+			require.NoError(t, b.Publish(&Event{Topic: "orders", Id: strconv.Itoa(i), Payload: &Payload{ResourceUri: key}}))
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen[fast]) == 10
+	}, time.Second, 5*time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, key := range []string{"slow", fast} {
+		require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, seen[key])
+	}
+}
+
+func TestBroker_WithOrderedByNilKey(t *testing.T) {
+	b := NewBroker()
+	got := make(chan string, 2)
+	b.SubscribeHandler("orders", func(e *Event) error {
+		got <- e.Id
+		return nil
+	}, WithOrderedBy(nil, 4))
+
+	// key 为 nil 时按 Id 分配分区
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "a"}))
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "b"}))
+	ids := []string{<-got, <-got}
+	require.ElementsMatch(t, []string{"a", "b"}, ids)
+}
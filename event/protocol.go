@@ -0,0 +1,166 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+)
+
+// ProtocolV1 WebSocket 控制协议的子协议名。
+// 客户端在 Sec-WebSocket-Protocol 中携带该值后，
+// 同一连接上可以动态订阅、取消订阅、确认和发布事件；未协商时连接只接收原始 Event JSON。
+const ProtocolV1 = "event.v1"
+
+// 帧类型
+const (
+	// FrameSubscribe 客户端订阅主题: {"type":"subscribe","id":"1","topic":"orders.#"}
+	FrameSubscribe = "subscribe"
+	// FrameUnsubscribe 客户端取消订阅: {"type":"unsubscribe","id":"2","topic":"orders.#"}
+	FrameUnsubscribe = "unsubscribe"
+	// FrameAck 客户端确认已处理事件: {"type":"ack","id":"3","eventId":"e1","sequence":42}
+	FrameAck = "ack"
+	// FramePing 客户端心跳: {"type":"ping","id":"4"}
+	FramePing = "ping"
+	// FramePublish 客户端发布事件: {"type":"publish","id":"5","event":{...}}
+	FramePublish = "publish"
+
+	// FrameEvent 服务端推送事件: {"type":"event","event":{...}}
+	FrameEvent = "event"
+	// FrameOK 服务端确认请求成功: {"type":"ok","id":"1"}
+	FrameOK = "ok"
+	// FramePong 服务端心跳响应: {"type":"pong","id":"4"}
+	FramePong = "pong"
+	// FrameError 服务端返回错误: {"type":"error","id":"1","code":"invalid_topic","message":"..."}
+	FrameError = "error"
+)
+
+// 错误帧的 code
+const (
+	ErrCodeInvalidMessage = "invalid_message"
+	ErrCodeUnknownType    = "unknown_type"
+	ErrCodeInvalidTopic   = "invalid_topic"
+	ErrCodePublishFailed  = "publish_failed"
+	ErrCodeForbidden      = "forbidden"
+	ErrCodeQuotaExceeded  = "quota_exceeded"
+	ErrCodeDuplicate      = "duplicate"
+	ErrCodeTopicPaused    = "topic_paused"
+)
+
+// Frame 控制协议消息，客户端请求中的 id 会原样带回对应的 ok/pong/error 帧
+type Frame struct {
+	Type     string `json:"type"`
+	Id       string `json:"id,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	EventId  string `json:"eventId,omitempty"`
+	Sequence uint64 `json:"sequence,omitempty"`
+	Event    *Event `json:"event,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// errorFrame 构造错误帧
+func errorFrame(id, code, message string) Frame {
+	return Frame{Type: FrameError, Id: id, Code: code, Message: message}
+}
+
+// wantsProtocol 判断能否与客户端协商 ProtocolV1，只接受客户端在 Sec-WebSocket-Protocol 中提出的子协议，
+// 否则客户端会因服务端返回了未提出的子协议而拒绝握手
+func (b *Broker) wantsProtocol(c *gin.Context) bool {
+	if len(b.upgrader.Subprotocols) > 0 {
+		return false
+	}
+	for _, p := range ws.Subprotocols(c.Request) {
+		if p == ProtocolV1 {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeConn 升级为 WebSocket 连接，客户端请求 ProtocolV1 时协商该子协议
+func (b *Broker) upgradeConn(c *gin.Context) (*ws.Conn, error) {
+	var header http.Header
+	if b.wantsProtocol(c) {
+		header = http.Header{"Sec-Websocket-Protocol": {ProtocolV1}}
+	}
+	return b.upgrader.Upgrade(c.Writer, c.Request, header)
+}
+
+// readFrames 读取并处理客户端的控制帧，直到连接关闭
+func (b *Broker) readFrames(c *client) {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var frame Frame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			c.send(errorFrame("", ErrCodeInvalidMessage, "malformed JSON frame"))
+			continue
+		}
+		c.send(b.handleFrame(c, &frame))
+	}
+}
+
+// handleFrame 处理一个控制帧并返回响应帧
+func (b *Broker) handleFrame(c *client, frame *Frame) Frame {
+	switch frame.Type {
+	case FrameSubscribe:
+		if frame.Topic == "" {
+			return errorFrame(frame.Id, ErrCodeInvalidTopic, "topic is required")
+		}
+		if !b.allowed(c.principal, ActionSubscribe, frame.Topic) {
+			return errorFrame(frame.Id, ErrCodeForbidden, "not allowed to subscribe to topic")
+		}
+		b.mu.Lock()
+		c.topics[frame.Topic] = struct{}{}
+		b.mu.Unlock()
+	case FrameUnsubscribe:
+		b.mu.Lock()
+		_, ok := c.topics[frame.Topic]
+		delete(c.topics, frame.Topic)
+		b.mu.Unlock()
+		if !ok {
+			return errorFrame(frame.Id, ErrCodeInvalidTopic, "not subscribed to topic")
+		}
+	case FrameAck:
+		if frame.Sequence > c.acked.Load() {
+			c.acked.Store(frame.Sequence)
+		}
+		c.ackCount.Add(1)
+	case FramePing:
+		return Frame{Type: FramePong, Id: frame.Id}
+	case FramePublish:
+		if frame.Event == nil || frame.Event.Topic == "" {
+			return errorFrame(frame.Id, ErrCodeInvalidMessage, "event with topic is required")
+		}
+		if !b.checkPublisher(c.principal, c.tenant, frame.Event) {
+			return errorFrame(frame.Id, ErrCodeForbidden, "not allowed to publish to topic")
+		}
+		if err := b.Publish(frame.Event); err != nil {
+			return errorFrame(frame.Id, publishErrorCode(err), err.Error())
+		}
+		return Frame{Type: FrameOK, Id: frame.Id, EventId: frame.Event.Id, Sequence: frame.Event.Sequence}
+	default:
+		return errorFrame(frame.Id, ErrCodeUnknownType, "unknown frame type "+frame.Type)
+	}
+	return Frame{Type: FrameOK, Id: frame.Id}
+}
+
+// publishErrorCode 返回发布失败对应的错误码
+func publishErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return ErrCodeQuotaExceeded
+	case errors.Is(err, ErrTopicPaused):
+		return ErrCodeTopicPaused
+	case errors.Is(err, ErrDuplicateEvent):
+		return ErrCodeDuplicate
+	default:
+		return ErrCodePublishFailed
+	}
+}
@@ -0,0 +1,83 @@
+package event
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_ProtocolV1(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	EventRouter(r.Group(""))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	dialer := ws.Dialer{Subprotocols: []string{ProtocolV1}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, ProtocolV1, conn.Subprotocol())
+
+	roundTrip := func(req Frame) Frame {
+		require.NoError(t, conn.WriteJSON(req))
+		var resp Frame
+		require.NoError(t, conn.ReadJSON(&resp))
+		return resp
+	}
+
+	require.Equal(t, Frame{Type: FramePong, Id: "1"}, roundTrip(Frame{Type: FramePing, Id: "1"}))
+	require.Equal(t, Frame{Type: FrameOK, Id: "2"}, roundTrip(Frame{Type: FrameSubscribe, Id: "2", Topic: "orders.*"}))
+	unknown := roundTrip(Frame{Type: "bogus", Id: "3"})
+	require.Equal(t, FrameError, unknown.Type)
+	require.Equal(t, ErrCodeUnknownType, unknown.Code)
+
+	require.NoError(t, conn.WriteJSON(Frame{Type: FramePublish, Id: "4", Event: &Event{Topic: "orders.created", Id: "e1"}}))
+	var frames []Frame
+	for len(frames) < 2 {
+		var f Frame
+		require.NoError(t, conn.ReadJSON(&f))
+		frames = append(frames, f)
+	}
+	var gotEvent bool
+	for _, f := range frames {
+		if f.Type == FrameEvent {
+			gotEvent = true
+			require.Equal(t, "e1", f.Event.Id)
+		} else {
+			require.Equal(t, FrameOK, f.Type)
+			require.Equal(t, "4", f.Id)
+		}
+	}
+	require.True(t, gotEvent)
+
+	require.Equal(t, Frame{Type: FrameOK, Id: "5"}, roundTrip(Frame{Type: FrameUnsubscribe, Id: "5", Topic: "orders.*"}))
+}
+
+func TestBroker_ProtocolNotOffered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	EventRouter(r.Group(""))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// 客户端未提出子协议时不协商，查询参数不能代替 Sec-WebSocket-Protocol
+	conn, resp, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events?protocol="+ProtocolV1, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Empty(t, resp.Header.Get("Sec-WebSocket-Protocol"))
+	require.Empty(t, conn.Subprotocol())
+
+	// 客户端提出的其他子协议也不会得到 ProtocolV1
+	dialer := ws.Dialer{Subprotocols: []string{"chat"}}
+	conn2, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events", nil)
+	require.NoError(t, err)
+	defer conn2.Close()
+	require.Empty(t, resp.Header.Get("Sec-WebSocket-Protocol"))
+}
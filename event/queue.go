@@ -0,0 +1,176 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OverflowPolicy 订阅者队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// DropOldest 丢弃队列中最早的事件，为新事件腾出位置
+	DropOldest OverflowPolicy = iota
+	// DropNewest 丢弃新到达的事件
+	DropNewest
+	// DisconnectSlow 断开处理过慢的订阅者
+	DisconnectSlow
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop_oldest"
+	case DropNewest:
+		return "drop_newest"
+	case DisconnectSlow:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// QueueOptions 每个订阅者出站队列的配置
+type QueueOptions struct {
+	Size   int
+	Policy OverflowPolicy
+}
+
+// DefaultQueueOptions 默认队列配置
+var DefaultQueueOptions = QueueOptions{
+	Size:   100,
+	Policy: DropOldest,
+}
+
+var (
+	meter          = otel.Meter("github.com/gagraler/pkg/event")
+	droppedCounter metric.Int64Counter
+)
+
+func init() {
+	var err error
+	droppedCounter, err = meter.Int64Counter("event.dropped",
+		metric.WithDescription("The number of events dropped by full subscriber queues"),
+		metric.WithUnit("{events}"))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// pushResult 入队结果
+type pushResult int
+
+const (
+	pushed pushResult = iota
+	dropped
+	overflowed
+)
+
+// outbox 订阅者的有界出站队列，入队永不阻塞
+type outbox struct {
+	mu      sync.Mutex
+	ch      chan *Event
+	policy  OverflowPolicy
+	closed  bool
+	dropped atomic.Int64
+}
+
+func newOutbox(opts QueueOptions) *outbox {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultQueueOptions.Size
+	}
+	return &outbox{
+		ch:     make(chan *Event, size),
+		policy: opts.Policy,
+	}
+}
+
+// push 将事件放入队列，队列已满时按策略处理
+func (o *outbox) push(event *Event) pushResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return dropped
+	}
+
+	select {
+	case o.ch <- event:
+		return pushed
+	default:
+	}
+
+	switch o.policy {
+	case DropOldest:
+		select {
+		case <-o.ch:
+		default:
+		}
+		select {
+		case o.ch <- event:
+		default:
+		}
+	case DisconnectSlow:
+		return overflowed
+	}
+	o.dropped.Add(1)
+	return dropped
+}
+
+// close 关闭队列，已入队的事件仍可被读取
+func (o *outbox) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.closed {
+		o.closed = true
+		close(o.ch)
+	}
+}
+
+// listener 进程内订阅者
+type listener struct {
+	topic      string
+	fn         Handler
+	outs       []*outbox // 每个分区一个队列和处理协程，未开启 WithOrderedBy 时只有一个分区
+	key        KeyFunc
+	partitions int
+	tenant     string // scoped 为 true 时只接收该租户的事件
+	scoped     bool
+	retry      RetryPolicy  // 处理失败时的重试策略
+	deadLetter string       // 重试耗尽后转发的死信主题，为空时不转发
+	retrying   atomic.Int32 // 在独立协程中等待重试的事件数
+}
+
+// enqueue 将事件放入订阅者队列并记录丢弃，返回 overflowed 表示应断开该订阅者
+func (b *Broker) enqueue(out *outbox, kind string, event *Event) pushResult {
+	result := out.push(event)
+	if result != pushed {
+		b.dropped.Add(1)
+		droppedCounter.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("subscriber", kind),
+			attribute.String("topic", event.Topic),
+			attribute.String("policy", out.policy.String()),
+		))
+	}
+	return result
+}
+
+// Dropped 返回因订阅者队列已满而丢弃的事件总数，不包括 Consume 通道的丢弃，见 BrokerStats.ConsumerDropped
+func (b *Broker) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// runHandler 订阅者分区的处理协程，按入队顺序依次处理事件
+func (b *Broker) runHandler(h *listener, out *outbox) {
+	defer b.wg.Done()
+	for event := range out.ch {
+		b.handle(h, event)
+		b.inflight.Add(-1)
+	}
+}
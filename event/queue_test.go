@@ -0,0 +1,58 @@
+package event
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOutbox_Push(t *testing.T) {
+	first, second, third := &Event{Id: "1"}, &Event{Id: "2"}, &Event{Id: "3"}
+
+	oldest := newOutbox(QueueOptions{Size: 2, Policy: DropOldest})
+	require.Equal(t, pushed, oldest.push(first))
+	require.Equal(t, pushed, oldest.push(second))
+	require.Equal(t, dropped, oldest.push(third))
+	require.Equal(t, second, <-oldest.ch)
+	require.Equal(t, third, <-oldest.ch)
+	require.Equal(t, int64(1), oldest.dropped.Load())
+
+	newest := newOutbox(QueueOptions{Size: 1, Policy: DropNewest})
+	require.Equal(t, pushed, newest.push(first))
+	require.Equal(t, dropped, newest.push(second))
+	require.Equal(t, first, <-newest.ch)
+
+	slow := newOutbox(QueueOptions{Size: 1, Policy: DisconnectSlow})
+	require.Equal(t, pushed, slow.push(first))
+	require.Equal(t, overflowed, slow.push(second))
+
+	slow.close()
+	require.Equal(t, dropped, slow.push(third))
+}
+
+func TestBroker_SubscribeDoesNotBlockPublish(t *testing.T) {
+	b := NewBroker()
+	b.SetQueueOptions(QueueOptions{Size: 1, Policy: DropNewest})
+
+	block := make(chan struct{})
+	b.Subscribe("orders.*", func(*Event) { <-block })
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, b.Publish(&Event{Topic: "orders.created"}))
+	}
+	require.Positive(t, b.Dropped())
+	close(block)
+}
+
+func TestBroker_ConsumerDropsNotCounted(t *testing.T) {
+	b := NewBroker()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, b.Publish(&Event{Topic: "orders"}))
+	}
+
+	// 未读取的 Consume 通道不影响订阅者的丢弃计数
+	require.Zero(t, b.Dropped())
+	stats := b.Stats("")
+	require.Zero(t, stats.Dropped)
+	require.Equal(t, int64(1000-DefaultQueueOptions.Size), stats.ConsumerDropped)
+	require.Len(t, b.Consume(), DefaultQueueOptions.Size)
+}
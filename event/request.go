@@ -0,0 +1,156 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// InboxTopicPrefix Request 生成的回复主题前缀，发布到该前缀下的事件不做主题注册和 schema 校验
+	InboxTopicPrefix = "_inbox."
+	// DefaultRequestTimeout /request 接口未指定 timeout 时的等待时间
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNoReplyTo 请求事件未携带回复主题
+	ErrNoReplyTo = errors.New("event: request has no reply topic")
+	// ErrRequestPending 租户中已有相同 CorrelationId 的请求在等待回复
+	ErrRequestPending = errors.New("event: request with the same correlation id is pending")
+)
+
+// pendingRequest 等待回复的请求
+type pendingRequest struct {
+	replyTo string
+	tenant  string
+	reply   chan *Event
+}
+
+// Request 发布请求事件并等待回复，回复是发布到 Payload.ReplyTo、CorrelationId 相同的第一个事件。
+// 未指定 CorrelationId 或 ReplyTo 时自动生成，同一租户中已有相同 CorrelationId 的请求在等待时返回 ErrRequestPending；
+// ctx 结束前未收到回复时返回 ctx.Err()
+func (b *Broker) Request(ctx context.Context, event *Event) (*Event, error) {
+	if event.Payload == nil {
+		event.Payload = &Payload{}
+	}
+	p := event.Payload
+	if p.CorrelationId == "" {
+		p.CorrelationId = uuid.NewString()
+	}
+	if p.ReplyTo == "" {
+		p.ReplyTo = InboxTopicPrefix + p.CorrelationId
+	}
+
+	key := pendingKey(p.TenantId, p.CorrelationId)
+	pending := &pendingRequest{replyTo: p.ReplyTo, tenant: p.TenantId, reply: make(chan *Event, 1)}
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string]*pendingRequest)
+	}
+	if _, ok := b.pending[key]; ok {
+		b.mu.Unlock()
+		return nil, ErrRequestPending
+	}
+	b.pending[key] = pending
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		if b.pending[key] == pending {
+			delete(b.pending, key)
+		}
+		b.mu.Unlock()
+	}()
+
+	if err := b.Publish(event); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-pending.reply:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Reply 将 reply 作为 request 的回复发布，回复的主题、CorrelationId 和租户取自请求
+func (b *Broker) Reply(request, reply *Event) error {
+	if request.Payload == nil || request.Payload.ReplyTo == "" {
+		return ErrNoReplyTo
+	}
+	if reply.Payload == nil {
+		reply.Payload = &Payload{}
+	}
+	reply.Topic = request.Payload.ReplyTo
+	reply.Payload.CorrelationId = request.Payload.CorrelationId
+	reply.Payload.TenantId = request.Payload.TenantId
+	reply.Payload.ReplyTo = ""
+	return b.Publish(reply)
+}
+
+// resolveReply 将回复交给等待中的请求，调用方需持有 b.mu
+func (b *Broker) resolveReply(event *Event) {
+	if event.Payload == nil || event.Payload.CorrelationId == "" {
+		return
+	}
+	pending, ok := b.pending[pendingKey(event.Payload.TenantId, event.Payload.CorrelationId)]
+	if !ok || pending.replyTo != event.Topic || pending.tenant != event.Payload.TenantId {
+		return
+	}
+	select {
+	case pending.reply <- event:
+	default: // 只取第一个回复
+	}
+}
+
+// pendingKey 等待回复的请求的索引，CorrelationId 只在租户内唯一
+func pendingKey(tenant, correlationId string) string {
+	return tenant + "/" + correlationId
+}
+
+// handleRequest 发布请求事件并等待回复，timeout 参数指定等待时间，如 "5s"，超时返回 504
+func (b *Broker) handleRequest(c *gin.Context) {
+	timeout := DefaultRequestTimeout
+	if s := c.Query("timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout"})
+			return
+		}
+		timeout = d
+	}
+
+	event, err := bindEvent(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if !b.checkPublisher(PrincipalFrom(c), requestTenant(c), event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": ActionPublish, "topic": event.Topic})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	reply, err := b.Request(ctx, event)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Timed out waiting for reply", "correlationId": event.Payload.CorrelationId})
+		return
+	case errors.Is(err, context.Canceled):
+		return
+	case err != nil:
+		b.writePublishError(c, event, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reply received", "event": reply})
+}
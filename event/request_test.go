@@ -0,0 +1,65 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Request(t *testing.T) {
+	b := NewBroker()
+	b.Subscribe("math.double", func(e *Event) {
+		n := e.Detail.(int)
+		require.NoError(t, b.Reply(e, &Event{Detail: n * 2}))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := b.Request(ctx, &Event{Topic: "math.double", Detail: 21})
+	require.NoError(t, err)
+	require.Equal(t, 42, reply.Detail)
+	require.NotEmpty(t, reply.Payload.CorrelationId)
+	require.Empty(t, b.pending)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = b.Request(ctx, &Event{Topic: "nobody.listens"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.ErrorIs(t, b.Reply(&Event{Topic: "math.double"}, &Event{}), ErrNoReplyTo)
+}
+
+func TestBroker_RequestPending(t *testing.T) {
+	b := NewBroker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.Request(ctx, &Event{Topic: "jobs", Payload: &Payload{CorrelationId: "c-1"}})
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// 相同 CorrelationId 的请求被拒绝，且不会移除等待中的请求
+	_, err := b.Request(context.Background(), &Event{Topic: "jobs", Payload: &Payload{CorrelationId: "c-1"}})
+	require.ErrorIs(t, err, ErrRequestPending)
+	b.mu.Lock()
+	require.Len(t, b.pending, 1)
+	b.mu.Unlock()
+
+	// 其他租户可以使用相同的 CorrelationId
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	_, err = b.Request(short, &Event{Topic: "jobs", Payload: &Payload{CorrelationId: "c-1", TenantId: "acme"}})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Empty(t, b.pending)
+}
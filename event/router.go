@@ -1,24 +1,80 @@
 package event
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"net/http"
 
-func EventRouter(r *gin.RouterGroup) {
+	"github.com/gin-gonic/gin"
+)
 
-	broker := NewBroker()
+// EventRouter 使用给定配置创建 Broker 并注册路由，返回创建的 Broker
+func EventRouter(r *gin.RouterGroup, opts ...Option) *Broker {
+	broker := NewBroker(opts...)
+	if err := BrokerRouter(r, broker); err != nil {
+		panic(fmt.Errorf("failed to setup event router: %s", err))
+	}
+	return broker
+}
+
+// BrokerRouter 为已创建的 Broker 注册路由，配置了数据库时先迁移数据表并启动定时事件的调度
+func BrokerRouter(r *gin.RouterGroup, broker *Broker) error {
+	if broker.db != nil {
+		if err := broker.AutoMigrate(); err != nil {
+			return err
+		}
+		go broker.RunScheduler(broker.ctx)
+	}
+
+	g := r.Group("", broker.rejectClosed, broker.authenticate)
 
 	// 订阅主题
-	r.POST("/subscribe", broker.handleSubscribe)
+	g.POST("/subscribe", broker.requireDB, broker.handleSubscribe)
 	// 发布事件
-	r.POST("/publish", broker.handlePublish)
+	g.POST("/publish", broker.handlePublish)
+	// 取消尚未发布的定时事件
+	g.DELETE("/scheduled/:id", broker.requireDB, broker.handleCancelScheduled)
+	// 发布请求事件并等待回复
+	g.POST("/request", broker.handleRequest)
 	// 接收事件推送，默认订阅主题为空
-	r.GET("/events", broker.handleEvents)
+	g.GET("/events", broker.handleEvents)
+	// 通过 Server-Sent Events 接收事件推送
+	g.GET("/events/stream", broker.handleStream)
 
+	// 主题管理需要 admin 权限
+	topics := g.Group("/topic", broker.requireDB, broker.authorizeAdmin)
 	// 创建新主题
-	r.POST("/topic", broker.handleCreateTopic)
+	topics.POST("", broker.handleCreateTopic)
 	// 获取所有主题
-	r.GET("/topic", broker.handleGetTopics)
+	topics.GET("", broker.handleGetTopics)
 	// 更新主题
-	r.POST("/topic/:id", broker.handleUpdateTopic)
+	topics.POST("/:id", broker.handleUpdateTopic)
 	// 删除主题
-	r.POST("/topic/:id", broker.handleDeleteTopic)
+	topics.DELETE("/:id", broker.handleDeleteTopic)
+
+	// 运行状态查询和管理需要 admin 权限
+	admin := g.Group("/admin", broker.authorizeAdmin)
+	// 整体状态
+	admin.GET("/stats", broker.handleGetStats)
+	// 当前连接
+	admin.GET("/connections", broker.handleGetConnections)
+	// 强制断开连接
+	admin.DELETE("/connections/:id", broker.handleDisconnect)
+	// 各主题的发布和投递计数
+	admin.GET("/topics", broker.handleGetTopicStats)
+	// 暂停和恢复主题
+	admin.POST("/topics/:topic/pause", broker.handlePauseTopic)
+	admin.POST("/topics/:topic/resume", broker.handleResumeTopic)
+	// 最近的发布和推送错误
+	admin.GET("/errors", broker.handleGetErrors)
+
+	return nil
+}
+
+// requireDB 未配置数据库时拒绝依赖数据库的请求
+func (b *Broker) requireDB(c *gin.Context) {
+	if b.db == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+		return
+	}
+	c.Next()
 }
@@ -0,0 +1,81 @@
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewBroker_Options(t *testing.T) {
+	db := newTestDB(t)
+	logger := zap.NewNop()
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	b := NewBroker(
+		WithDB(db),
+		WithLogger(logger),
+		WithLogger(nil),
+		WithBufferSize(8),
+		WithBufferSize(0),
+		WithOverflowPolicy(DropNewest),
+		WithRetryPolicy(policy),
+		WithHeartbeatInterval(time.Minute),
+		WithScheduleInterval(time.Minute),
+	)
+	require.Same(t, db, b.db)
+	require.Same(t, logger, b.logger)
+	require.Equal(t, QueueOptions{Size: 8, Policy: DropNewest}, b.queueOptions)
+	require.Equal(t, policy, b.retryPolicy)
+	require.Equal(t, time.Minute, b.heartbeatInterval)
+	require.Equal(t, time.Minute, b.scheduleInterval)
+	require.Equal(t, 8, cap(b.eventsCh.ch))
+
+	// 未设置时使用默认值
+	b = NewBroker()
+	require.Nil(t, b.db)
+	require.Equal(t, DefaultQueueOptions, b.queueOptions)
+	require.Equal(t, DefaultRetryPolicy, b.retryPolicy)
+	require.Equal(t, DefaultHeartbeatInterval, b.heartbeatInterval)
+}
+
+func TestBrokerRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 配置数据库时迁移数据表，订阅保存到数据库
+	r := gin.New()
+	b := NewBroker(WithDB(newTestDB(t)))
+	require.NoError(t, BrokerRouter(r.Group("/events"), b))
+	require.True(t, b.db.Migrator().HasTable(&Subscription{}))
+
+	w := httptest.NewRecorder()
+	body := `{"topic":"orders","client":"billing","callbackUrl":"http://localhost/hook"}`
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/subscribe", strings.NewReader(body)))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var count int64
+	require.NoError(t, b.db.Model(&Subscription{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+
+	// 未配置数据库时依赖数据库的路由返回 503
+	r = gin.New()
+	EventRouter(r.Group(""))
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(body)),
+		httptest.NewRequest(http.MethodGet, "/topic", nil),
+		httptest.NewRequest(http.MethodDelete, "/scheduled/1", nil),
+	} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusServiceUnavailable, w.Code, req.URL.Path)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{"topic":"orders"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+}
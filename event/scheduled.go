@@ -0,0 +1,258 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultScheduleInterval 检查到期定时事件的默认间隔
+const DefaultScheduleInterval = time.Second
+
+// scheduleBatchSize 每次检查最多释放的定时事件数
+const scheduleBatchSize = 100
+
+// scheduleLease 释放定时事件时占用记录的时长，实例在发布完成前崩溃时租约到期后由其他实例重新发布
+const scheduleLease = time.Minute
+
+var (
+	// ErrScheduledNotFound 定时事件不存在或已释放
+	ErrScheduledNotFound = errors.New("event: scheduled event not found")
+	// ErrInvalidSchedule delay 或 deliverAt 参数无效
+	ErrInvalidSchedule = errors.New("event: invalid schedule")
+)
+
+// ScheduledEvent 等待在 DeliverAt 发布的事件，发布成功或取消后删除
+type ScheduledEvent struct {
+	ID           uint `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time
+	TenantId     string     `gorm:"uniqueIndex:idx_tenant_event_id;size:64;not null;default:''" json:"tenantId,omitempty"`
+	EventId      string     `gorm:"uniqueIndex:idx_tenant_event_id;size:64;not null" json:"eventId"`
+	Topic        string     `gorm:"size:255;not null" json:"topic"`
+	DeliverAt    time.Time  `gorm:"index;not null" json:"deliverAt"`
+	ClaimedUntil *time.Time `json:"-"` // 正在被某个实例发布，到期前其他实例不会释放，也不能取消
+	Data         []byte     `gorm:"not null" json:"-"`
+}
+
+// PublishAt 在 at 时刻发布事件，at 已过时立即发布。事件保存在数据库中，重启后仍会按时发布；
+// 未指定 Id 时自动生成，可通过 CancelScheduled 按 Id 取消
+func (b *Broker) PublishAt(event *Event, at time.Time) error {
+	if !at.After(time.Now()) {
+		return b.Publish(event)
+	}
+	if b.db == nil {
+		return ErrNoDatabase
+	}
+
+	// 提前校验，避免到期时才发现事件无法发布
+	if err := b.validate(event); err != nil {
+		return err
+	}
+	if event.Id == "" {
+		event.Id = uuid.NewString()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	scheduled := ScheduledEvent{
+		TenantId:  tenantOf(event),
+		EventId:   event.Id,
+		Topic:     event.Topic,
+		DeliverAt: at,
+		Data:      data,
+	}
+	if err := b.db.Create(&scheduled).Error; err != nil {
+		b.logger.Error("Failed to schedule event", zap.String("id", event.Id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// PublishAfter 在 delay 之后发布事件，见 PublishAt
+func (b *Broker) PublishAfter(event *Event, delay time.Duration) error {
+	return b.PublishAt(event, time.Now().Add(delay))
+}
+
+// CancelScheduled 取消租户 tenant 中尚未发布的定时事件，正在发布的事件无法取消
+func (b *Broker) CancelScheduled(tenant, id string) error {
+	if b.db == nil {
+		return ErrNoDatabase
+	}
+	result := b.db.Where("tenant_id = ? AND event_id = ?", tenant, id).
+		Where("(claimed_until IS NULL OR claimed_until < ?)", time.Now()).
+		Delete(&ScheduledEvent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrScheduledNotFound
+	}
+	return nil
+}
+
+// RunScheduler 定期发布到期的定时事件，直到 ctx 结束。BrokerRouter 会自动启动，
+// 多个实例共用数据库时每个事件只会被其中一个实例发布
+func (b *Broker) RunScheduler(ctx context.Context) {
+	if b.db == nil {
+		return
+	}
+
+	ticker := time.NewTicker(b.scheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.releaseDue(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// releaseDue 发布 now 之前到期的定时事件。先占用记录，发布成功后删除；发布失败时释放占用，
+// 下次检查时重试，占用成功的实例才发布该事件
+func (b *Broker) releaseDue(now time.Time) {
+	var due []ScheduledEvent
+	err := b.db.Where("deliver_at <= ?", now).
+		Where("(claimed_until IS NULL OR claimed_until < ?)", now).
+		Order("deliver_at ASC").Limit(scheduleBatchSize).Find(&due).Error
+	if err != nil {
+		b.logger.Error("Failed to fetch scheduled events", zap.Error(err))
+		return
+	}
+
+	for _, scheduled := range due {
+		if !b.claimScheduled(scheduled, now) {
+			continue
+		}
+
+		if !b.publishScheduled(scheduled) {
+			b.releaseScheduled(scheduled)
+			continue
+		}
+
+		if err := b.db.Delete(&ScheduledEvent{}, scheduled.ID).Error; err != nil {
+			b.logger.Error("Failed to delete scheduled event", zap.String("id", scheduled.EventId), zap.Error(err))
+		}
+	}
+}
+
+// claimScheduled 占用到期的定时事件，返回 false 表示已被取消或被其他实例占用
+func (b *Broker) claimScheduled(scheduled ScheduledEvent, now time.Time) bool {
+	result := b.db.Model(&ScheduledEvent{}).
+		Where("id = ? AND (claimed_until IS NULL OR claimed_until < ?)", scheduled.ID, now).
+		Update("claimed_until", now.Add(scheduleLease))
+	if result.Error != nil {
+		b.logger.Error("Failed to claim scheduled event", zap.String("id", scheduled.EventId), zap.Error(result.Error))
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// publishScheduled 发布已占用的定时事件，返回 false 表示发布失败且应当重试。
+// 无法解码或校验失败的事件重试也不会成功，记录日志后返回 true
+func (b *Broker) publishScheduled(scheduled ScheduledEvent) bool {
+	var event Event
+	if err := json.Unmarshal(scheduled.Data, &event); err != nil {
+		b.logger.Error("Failed to decode scheduled event", zap.String("id", scheduled.EventId), zap.Error(err))
+		return true
+	}
+
+	err := b.Publish(&event)
+	var schemaErr *SchemaError
+	switch {
+	case err == nil, errors.Is(err, ErrDuplicateEvent):
+		// 租约到期后重新发布的事件可能已经发布过
+		return true
+	case errors.As(err, &schemaErr), errors.Is(err, ErrTopicNotFound):
+		b.logger.Error("Dropped invalid scheduled event", zap.String("id", scheduled.EventId), zap.Error(err))
+		return true
+	default:
+		b.logger.Warn("Failed to publish scheduled event, will retry", zap.String("id", scheduled.EventId), zap.Error(err))
+		return false
+	}
+}
+
+// releaseScheduled 释放占用，使事件在下次检查时重新发布
+func (b *Broker) releaseScheduled(scheduled ScheduledEvent) {
+	err := b.db.Model(&ScheduledEvent{}).Where("id = ?", scheduled.ID).Update("claimed_until", nil).Error
+	if err != nil {
+		b.logger.Error("Failed to release scheduled event", zap.String("id", scheduled.EventId), zap.Error(err))
+	}
+}
+
+// dropSoftDeletedSchedules 清理旧版本软删除的定时事件并删除 deleted_at 列，
+// 否则已取消的事件会重新被释放并占用 idx_tenant_event_id
+func dropSoftDeletedSchedules(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn(&ScheduledEvent{}, "deleted_at") {
+		return nil
+	}
+	if err := db.Where("deleted_at IS NOT NULL").Delete(&ScheduledEvent{}).Error; err != nil {
+		return err
+	}
+	return m.DropColumn(&ScheduledEvent{}, "deleted_at")
+}
+
+// scheduleTime 解析 delay（如 "15m"）或 deliverAt（RFC 3339）参数，均未指定时返回零值
+func scheduleTime(delay, deliverAt string, now time.Time) (time.Time, error) {
+	switch {
+	case delay != "" && deliverAt != "":
+		return time.Time{}, ErrInvalidSchedule
+	case delay != "":
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return time.Time{}, ErrInvalidSchedule
+		}
+		return now.Add(d), nil
+	case deliverAt != "":
+		at, err := time.Parse(time.RFC3339, deliverAt)
+		if err != nil {
+			return time.Time{}, ErrInvalidSchedule
+		}
+		return at, nil
+	}
+	return time.Time{}, nil
+}
+
+// handleCancelScheduled 按事件 Id 取消定时事件，需要该事件主题的发布权限
+func (b *Broker) handleCancelScheduled(c *gin.Context) {
+	tenant := requestTenant(c)
+	id := c.Param("id")
+
+	var scheduled ScheduledEvent
+	err := b.db.Where("tenant_id = ? AND event_id = ?", tenant, id).First(&scheduled).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled event"})
+		return
+	}
+
+	if !b.authorize(c, ActionPublish, scheduled.Topic) {
+		return
+	}
+
+	err = b.CancelScheduled(tenant, id)
+	if errors.Is(err, ErrScheduledNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled event"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
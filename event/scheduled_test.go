@@ -0,0 +1,138 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	at, err := scheduleTime("15m", "", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(15*time.Minute), at)
+
+	at, err = scheduleTime("", "2024-05-02T09:00:00Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC), at)
+
+	at, err = scheduleTime("", "", now)
+	require.NoError(t, err)
+	require.True(t, at.IsZero())
+
+	for _, c := range [][2]string{{"15m", "2024-05-02T09:00:00Z"}, {"-1s", ""}, {"soon", ""}, {"", "tomorrow"}} {
+		_, err = scheduleTime(c[0], c[1], now)
+		require.ErrorIs(t, err, ErrInvalidSchedule)
+	}
+}
+
+func TestBroker_PublishAt(t *testing.T) {
+	b := NewBroker()
+	got := make(chan *Event, 1)
+	b.Subscribe("reminders", func(e *Event) { got <- e })
+
+	// 已到期的事件立即发布
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders"}, time.Now().Add(-time.Second)))
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatal("due event was not published")
+	}
+
+	require.ErrorIs(t, b.PublishAfter(&Event{Topic: "reminders"}, time.Minute), ErrNoDatabase)
+	require.ErrorIs(t, b.CancelScheduled("", "missing"), ErrNoDatabase)
+}
+
+func TestBroker_ReleaseDue(t *testing.T) {
+	b := newTestBroker(t)
+	got := make(chan *Event, 4)
+	b.Subscribe("reminders", func(e *Event) { got <- e })
+
+	now := time.Now()
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-1"}, now.Add(time.Minute)))
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-2"}, now.Add(time.Hour)))
+
+	// 未到期的事件不会发布
+	b.releaseDue(now)
+	require.Empty(t, got)
+
+	b.releaseDue(now.Add(2 * time.Minute))
+	select {
+	case e := <-got:
+		require.Equal(t, "r-1", e.Id)
+	case <-time.After(time.Second):
+		t.Fatal("due event was not published")
+	}
+
+	var count int64
+	require.NoError(t, b.db.Model(&ScheduledEvent{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
+
+func TestBroker_CancelScheduled(t *testing.T) {
+	b := newTestBroker(t)
+	got := make(chan *Event, 1)
+	b.Subscribe("reminders", func(e *Event) { got <- e })
+
+	at := time.Now().Add(time.Minute)
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-1"}, at))
+	require.ErrorIs(t, b.CancelScheduled("acme", "r-1"), ErrScheduledNotFound)
+	require.NoError(t, b.CancelScheduled("", "r-1"))
+	require.ErrorIs(t, b.CancelScheduled("", "r-1"), ErrScheduledNotFound)
+
+	// 取消后记录被删除，不会再发布，同一个 Id 可以重新调度
+	b.releaseDue(at.Add(time.Second))
+	require.Empty(t, got)
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-1"}, at))
+
+	// 正在发布的事件无法取消，也不会被重复释放
+	var scheduled ScheduledEvent
+	require.NoError(t, b.db.First(&scheduled).Error)
+	require.True(t, b.claimScheduled(scheduled, time.Now()))
+	require.False(t, b.claimScheduled(scheduled, time.Now()))
+	require.ErrorIs(t, b.CancelScheduled("", "r-1"), ErrScheduledNotFound)
+}
+
+func TestBroker_ReleaseDueRetry(t *testing.T) {
+	b := newTestBroker(t)
+	got := make(chan *Event, 1)
+	b.Subscribe("reminders", func(e *Event) { got <- e })
+
+	at := time.Now().Add(time.Minute)
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-1"}, at))
+
+	// 发布失败时保留记录并释放占用，下次检查时重试
+	b.PauseTopic("", "reminders")
+	b.releaseDue(at)
+	require.Empty(t, got)
+
+	var scheduled ScheduledEvent
+	require.NoError(t, b.db.First(&scheduled).Error)
+	require.Nil(t, scheduled.ClaimedUntil)
+
+	b.ResumeTopic("", "reminders")
+	b.releaseDue(at)
+	select {
+	case e := <-got:
+		require.Equal(t, "r-1", e.Id)
+	case <-time.After(time.Second):
+		t.Fatal("scheduled event was not retried")
+	}
+
+	// 实例崩溃遗留的占用在租约到期后重新释放
+	require.NoError(t, b.PublishAt(&Event{Topic: "reminders", Id: "r-2"}, at))
+	scheduled = ScheduledEvent{}
+	require.NoError(t, b.db.First(&scheduled, "event_id = ?", "r-2").Error)
+	require.True(t, b.claimScheduled(scheduled, at))
+	b.releaseDue(at)
+	require.Empty(t, got)
+	b.releaseDue(at.Add(scheduleLease + time.Second))
+	select {
+	case e := <-got:
+		require.Equal(t, "r-2", e.Id)
+	case <-time.After(time.Second):
+		t.Fatal("expired claim was not released")
+	}
+}
@@ -0,0 +1,137 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gorm.io/gorm"
+)
+
+// ErrTopicNotFound 要求主题已注册时，事件主题不存在
+var ErrTopicNotFound = errors.New("event: topic not registered")
+
+// SchemaViolation 一条 JSON Schema 校验错误
+type SchemaViolation struct {
+	Path    string `json:"path"`    // Detail 中出错的位置，JSON Pointer 格式
+	Keyword string `json:"keyword"` // 未通过的 schema 关键字位置
+	Message string `json:"message"`
+}
+
+// SchemaError 事件 Detail 未通过主题的 JSON Schema 校验
+type SchemaError struct {
+	Topic      string            `json:"topic"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Path+": "+v.Message)
+	}
+	return fmt.Sprintf("event: detail does not match schema of topic %q: %s", e.Topic, strings.Join(messages, "; "))
+}
+
+// compiledSchema 按租户主题缓存的已编译 schema，主题更新后重新编译
+type compiledSchema struct {
+	updatedAt time.Time
+	schema    *jsonschema.Schema
+}
+
+// schemaCache 已编译 schema 的缓存
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]compiledSchema
+}
+
+// get 返回主题当前 schema 的编译结果
+func (c *schemaCache) get(topic *Topic) (*jsonschema.Schema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := topic.TenantId + "/" + topic.Name
+	if cached, ok := c.schemas[key]; ok && cached.updatedAt.Equal(topic.UpdatedAt) {
+		return cached.schema, nil
+	}
+
+	schema, err := compileSchema(topic.Name, topic.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if c.schemas == nil {
+		c.schemas = make(map[string]compiledSchema)
+	}
+	c.schemas[key] = compiledSchema{updatedAt: topic.UpdatedAt, schema: schema}
+	return schema, nil
+}
+
+// compileSchema 编译主题的 JSON Schema
+func compileSchema(topic, schema string) (*jsonschema.Schema, error) {
+	return jsonschema.CompileString("topic:"+topic+".json", schema)
+}
+
+// validate 校验事件主题是否已注册，以及 Detail 是否符合主题的 JSON Schema
+func (b *Broker) validate(event *Event) error {
+	if b.db == nil || strings.HasPrefix(event.Topic, InboxTopicPrefix) {
+		return nil
+	}
+
+	var topic Topic
+	err := b.db.Where("tenant_id = ? AND name = ?", tenantOf(event), event.Topic).First(&topic).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if b.requireTopic {
+			return ErrTopicNotFound
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if topic.Schema == "" {
+		return nil
+	}
+
+	schema, err := b.schemas.get(&topic)
+	if err != nil {
+		return err
+	}
+
+	return validateDetail(event.Topic, schema, event.Detail)
+}
+
+// validateDetail 使用 schema 校验事件 Detail，不符合时返回 *SchemaError
+func validateDetail(topic string, schema *jsonschema.Schema, detail interface{}) error {
+	// 转换为 schema 校验所需的通用 JSON 值
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	var validationErr *jsonschema.ValidationError
+	err = schema.Validate(value)
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	schemaErr := &SchemaError{Topic: topic}
+	for _, e := range validationErr.BasicOutput().Errors {
+		// 跳过根节点的汇总错误，只保留具体的违规项
+		if e.KeywordLocation == "" && len(validationErr.Causes) > 0 {
+			continue
+		}
+		schemaErr.Violations = append(schemaErr.Violations, SchemaViolation{
+			Path:    e.InstanceLocation,
+			Keyword: e.KeywordLocation,
+			Message: e.Error,
+		})
+	}
+	return schemaErr
+}
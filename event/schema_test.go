@@ -0,0 +1,33 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDetail(t *testing.T) {
+	schema, err := compileSchema("orders.created", `{
+		"type": "object",
+		"required": ["orderId", "amount"],
+		"properties": {
+			"orderId": {"type": "string"},
+			"amount": {"type": "number", "minimum": 0}
+		}
+	}`)
+	require.NoError(t, err)
+
+	require.NoError(t, validateDetail("orders.created", schema, map[string]interface{}{"orderId": "42", "amount": 10}))
+
+	err = validateDetail("orders.created", schema, map[string]interface{}{"amount": -1})
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	require.Equal(t, "orders.created", schemaErr.Topic)
+	require.Len(t, schemaErr.Violations, 2)
+
+	paths := []string{schemaErr.Violations[0].Path, schemaErr.Violations[1].Path}
+	require.ElementsMatch(t, []string{"", "/amount"}, paths)
+
+	_, err = compileSchema("broken", `{"type": 1}`)
+	require.Error(t, err)
+}
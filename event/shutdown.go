@@ -0,0 +1,119 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+)
+
+// ErrBrokerClosed Broker 已关闭，不再接受发布和订阅
+var ErrBrokerClosed = errors.New("event: broker closed")
+
+// shutdownReason 关闭时发给 WebSocket 客户端的关闭原因
+const shutdownReason = "server shutting down"
+
+// drainInterval 关闭时检查队列是否已排空的间隔
+const drainInterval = 10 * time.Millisecond
+
+// Shutdown 优雅关闭 Broker：停止接受发布、订阅和新连接，等待进行中的发布、各进程内订阅者队列中的事件
+// （包括等待重试的事件及其死信事件）以及 webhook 推送完成，然后关闭队列，WebSocket 客户端收到队列中剩余的事件后
+// 收到 CloseGoingAway 关闭帧，最后关闭 Consume 返回的通道。
+// ctx 结束时停止等待：订阅者和 webhook 推送不再重试，webhook 推送写入死信表，剩余连接被强制关闭，返回 ctx.Err()
+func (b *Broker) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closing.Load() {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	b.closing.Store(true)
+	b.mu.Unlock()
+
+	// 排空期间仍接受死信等内部发布
+	err := b.drain(ctx)
+	if err != nil {
+		b.cancel()
+	}
+
+	b.mu.Lock()
+	b.closed.Store(true)
+	clients := make([]*client, 0, len(b.clients))
+	for c := range b.clients {
+		c.closeCode, c.closeText = ws.CloseGoingAway, shutdownReason
+		b.detachClient(c)
+		clients = append(clients, c)
+	}
+	for _, handlers := range b.topics {
+		for _, h := range handlers {
+			for _, out := range h.outs {
+				out.close()
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	// 关闭队列后写协程先写完队列中剩余的事件再断开
+	for _, c := range clients {
+		c.out.close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		for _, c := range clients {
+			<-c.done
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		for _, c := range clients {
+			c.close()
+		}
+	}
+
+	b.cancel()
+	b.eventsCh.close()
+	return err
+}
+
+// drain 等待进行中的发布、推送和进程内订阅者队列中的事件处理完成，ctx 结束时返回 ctx.Err()
+func (b *Broker) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for b.inflight.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// rejectClosed 关闭后拒绝新的请求
+func (b *Broker) rejectClosed(c *gin.Context) {
+	if b.closing.Load() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Broker shutting down"})
+		return
+	}
+	c.Next()
+}
+
+// sleep 等待 d，Broker 关闭时提前返回 false
+func (b *Broker) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
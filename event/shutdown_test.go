@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Shutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	broker := EventRouter(r.Group(""))
+
+	var handled atomic.Int64
+	broker.Subscribe("orders", func(*Event) {
+		time.Sleep(10 * time.Millisecond)
+		handled.Add(1)
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events?topic=orders", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return broker.Stats("").Clients == 1 }, time.Second, 5*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, broker.Publish(&Event{Topic: "orders"}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, broker.Shutdown(ctx))
+	require.Equal(t, int64(3), handled.Load())
+
+	// 队列中的事件先送达，随后收到关闭帧
+	for i := 0; i < 3; i++ {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, "orders", event.Topic)
+	}
+	_, _, err = conn.ReadMessage()
+	require.True(t, ws.IsCloseError(err, ws.CloseGoingAway), err)
+
+	_, ok := <-broker.Consume()
+	require.True(t, ok)
+	require.ErrorIs(t, broker.Publish(&Event{Topic: "orders"}), ErrBrokerClosed)
+	require.ErrorIs(t, broker.Shutdown(ctx), ErrBrokerClosed)
+}
+
+func TestBroker_ShutdownDrainsRetries(t *testing.T) {
+	b := NewBroker()
+
+	deadLetters := make(chan *Event, 1)
+	b.Subscribe("orders.dlq", func(e *Event) { deadLetters <- e })
+	b.SubscribeHandler("orders", func(*Event) error {
+		return errors.New("unavailable")
+	}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 20 * time.Millisecond}), WithDeadLetterTopic("orders.dlq"))
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "o-1"}))
+
+	// 等待重试完成，死信事件在关闭过程中仍能发布和处理
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, b.Shutdown(ctx))
+	select {
+	case e := <-deadLetters:
+		require.Equal(t, 3, e.Detail.(DeadLetterDetail).Attempts)
+	default:
+		t.Fatal("dead letter event was not handled before shutdown returned")
+	}
+}
+
+func TestBroker_ShutdownTimeout(t *testing.T) {
+	b := NewBroker()
+	b.SubscribeHandler("orders", func(*Event) error {
+		return errors.New("unavailable")
+	}, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders"}))
+	require.Eventually(t, func() bool { return len(b.RecentErrors()) == 0 && b.inflight.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// 超时后停止等待重试
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.ErrorIs(t, b.Shutdown(ctx), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+	require.Eventually(t, func() bool { return len(b.RecentErrors()) == 1 }, time.Second, 5*time.Millisecond)
+}
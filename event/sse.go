@@ -0,0 +1,136 @@
+package event
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DefaultHeartbeatInterval SSE 心跳注释的默认发送间隔
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// handleStream 通过 Server-Sent Events 推送事件。
+// topic 指定订阅主题（支持通配符），format=cloudevents 时以 CloudEvents structured JSON 推送；配置了数据库时，Last-Event-ID 请求头或 last_event_id 参数
+// 用于断线重连后从该序号之后继续推送。
+func (b *Broker) handleStream(c *gin.Context) {
+	topic := c.Query("topic")
+	format := c.Query("format")
+	if format != FormatNative && format != FormatCloudEvents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+
+	if !b.authorize(c, ActionSubscribe, topic) {
+		return
+	}
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventId != "" {
+		id, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		after = id
+	}
+
+	tenant := requestTenant(c)
+	if !b.acquireConn(c, tenant) {
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+
+	// 与 WebSocket 客户端共用分发路径，配置了数据库时先分页回放断线期间错过的事件
+	cli := b.newClient(nil, c.ClientIP(), PrincipalFrom(c), tenant, topic)
+	cli.format = format
+	register := func() { b.registerClient(cli) }
+	write := func(event *Event) error { return writeSSE(c.Writer, cli, event) }
+
+	var events []*Event
+	if after > 0 && b.db != nil {
+		fetch := func(limit int) ([]*Event, bool, error) {
+			events, next, more, err := b.replayAfter(tenant, topic, after, limit)
+			after = next
+			return events, more, err
+		}
+		var err error
+		events, err = b.catchUp(fetch, write, register)
+		if err != nil {
+			b.tenants.releaseConn(tenant)
+			b.logger.Error("Failed to replay events", zap.String("topic", topic), zap.Error(err))
+			if !c.Writer.Written() {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay events"})
+			}
+			return
+		}
+	} else {
+		b.mu.Lock()
+		register()
+		b.mu.Unlock()
+	}
+	defer close(cli.done)
+	defer b.removeClient(cli)
+
+	c.Status(http.StatusOK)
+	for _, event := range events {
+		if err := write(event); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(b.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case event, ok := <-cli.out.ch:
+			if !ok {
+				return
+			}
+			if err := writeSSE(c.Writer, cli, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeSSE 以 SSE 格式写入一个事件，持久化过的事件使用全局序号作为 id
+func writeSSE(w http.ResponseWriter, cli *client, event *Event) error {
+	data, err := cli.encode(event)
+	if err != nil {
+		return err
+	}
+	if event.Sequence > 0 {
+		n, err := fmt.Fprintf(w, "id: %d\n", event.Sequence)
+		cli.bytesSent.Add(int64(n))
+		if err != nil {
+			return err
+		}
+	}
+	n, err := fmt.Fprintf(w, "data: %s\n\n", data)
+	cli.bytesSent.Add(int64(n))
+	return err
+}
@@ -0,0 +1,42 @@
+package event
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_HandleStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	broker := EventRouter(r.Group(""), WithHeartbeatInterval(time.Hour))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events/stream?topic=orders.*")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// 等待客户端注册完成
+	require.Eventually(t, func() bool {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		return len(broker.clients) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, broker.Publish(&Event{Topic: "users.created", Id: "skip"}))
+	require.NoError(t, broker.Publish(&Event{Topic: "orders.created", Id: "keep"}))
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "data: "))
+	require.Contains(t, line, `"id":"keep"`)
+}
@@ -0,0 +1,221 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoDatabase Broker 未配置数据库
+var ErrNoDatabase = errors.New("event: broker has no database")
+
+// StoredEvent 持久化的事件，Offset 在同一租户的同一主题内单调递增
+type StoredEvent struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TenantId  string `gorm:"uniqueIndex:idx_tenant_topic_offset;size:64;not null;default:''"`
+	Topic     string `gorm:"uniqueIndex:idx_tenant_topic_offset;size:255;not null"`
+	Offset    int64  `gorm:"column:event_offset;uniqueIndex:idx_tenant_topic_offset;not null"`
+	EventId   string `gorm:"index;size:64"`
+	Data      []byte `gorm:"not null"`
+}
+
+// TableName 事件表名
+func (StoredEvent) TableName() string {
+	return "events"
+}
+
+// AutoMigrate 创建或更新 Broker 依赖的数据表
+func (b *Broker) AutoMigrate() error {
+	if b.db == nil {
+		return ErrNoDatabase
+	}
+	if err := b.db.AutoMigrate(&Topic{}, &Subscription{}, &StoredEvent{}, &DeadLetter{}, &ScheduledEvent{}); err != nil {
+		return err
+	}
+	if err := dropSoftDeletedSchedules(b.db); err != nil {
+		return err
+	}
+	return dropLegacyIndexes(b.db)
+}
+
+// persistAttempts 多个实例同时为同一租户主题分配 offset 时，写入事件的最大尝试次数
+const persistAttempts = 5
+
+// lockTopic 锁定事件所属的租户主题，返回解锁函数
+func (b *Broker) lockTopic(event *Event) func() {
+	v, _ := b.topicLocks.LoadOrStore(topicKey{tenant: tenantOf(event), topic: event.Topic}, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// persist 将事件写入事件表，并为其分配租户主题内的下一个 offset。
+// 共享数据库的其他实例先占用了该 offset 时，违反唯一索引的写入失败，重新分配后再试
+func (b *Broker) persist(event *Event) error {
+	var err error
+	for i := 0; i < persistAttempts; i++ {
+		if err = b.insertEvent(event); err == nil || !b.offsetTaken(event) {
+			return err
+		}
+	}
+	return err
+}
+
+// offsetTaken 判断事件的 offset 是否已被占用
+func (b *Broker) offsetTaken(event *Event) bool {
+	var count int64
+	err := b.db.Model(&StoredEvent{}).
+		Where("tenant_id = ? AND topic = ? AND event_offset = ?", tenantOf(event), event.Topic, event.Offset).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+// insertEvent 读取租户主题当前最大的 offset 并写入事件
+func (b *Broker) insertEvent(event *Event) error {
+	tenant := tenantOf(event)
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var last int64
+		err := tx.Model(&StoredEvent{}).
+			Where("tenant_id = ? AND topic = ?", tenant, event.Topic).
+			Select("COALESCE(MAX(event_offset), 0)").
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+
+		event.Offset = last + 1
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		stored := StoredEvent{
+			TenantId: tenant,
+			Topic:    event.Topic,
+			Offset:   event.Offset,
+			EventId:  event.Id,
+			Data:     data,
+		}
+		if err := tx.Create(&stored).Error; err != nil {
+			return err
+		}
+		event.Sequence = uint64(stored.ID)
+		return nil
+	})
+}
+
+// Replay 按 offset 顺序读取默认租户主题中 offset >= fromOffset 的事件，limit <= 0 表示不限制数量
+func (b *Broker) Replay(topic string, fromOffset int64, limit int) ([]*Event, error) {
+	return b.ReplayTenant("", topic, fromOffset, limit)
+}
+
+// ReplayTenant 按 offset 顺序读取租户主题中 offset >= fromOffset 的事件，limit <= 0 表示不限制数量
+func (b *Broker) ReplayTenant(tenant, topic string, fromOffset int64, limit int) ([]*Event, error) {
+	if b.db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	query := b.db.Where("tenant_id = ? AND topic = ? AND event_offset >= ?", tenant, topic, fromOffset).
+		Order("event_offset ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	return findEvents(query, "")
+}
+
+// replayAfter 按全局序号顺序读取租户中序号大于 after 的最多 limit 条记录（limit <= 0 表示不限制），
+// 返回其中匹配 pattern 的事件、下一页的起点，以及读满 limit 条时 more 为 true
+func (b *Broker) replayAfter(tenant, pattern string, after uint64, limit int) (events []*Event, next uint64, more bool, err error) {
+	if b.db == nil {
+		return nil, after, false, ErrNoDatabase
+	}
+
+	query := b.db.Where("tenant_id = ? AND id > ?", tenant, after).Order("id ASC")
+	if !IsTopicPattern(pattern) {
+		query = query.Where("topic = ?", pattern)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var stored []StoredEvent
+	if err := query.Find(&stored).Error; err != nil {
+		return nil, after, false, err
+	}
+	next = after
+	if len(stored) > 0 {
+		next = uint64(stored[len(stored)-1].ID)
+	}
+	events, err = decodeEvents(stored, pattern)
+	return events, next, limit > 0 && len(stored) == limit, err
+}
+
+// findEvents 执行查询并解码事件
+func findEvents(query *gorm.DB, pattern string) ([]*Event, error) {
+	var stored []StoredEvent
+	if err := query.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	return decodeEvents(stored, pattern)
+}
+
+// decodeEvents 解码持久化的事件，pattern 非空时过滤不匹配的主题
+func decodeEvents(stored []StoredEvent, pattern string) ([]*Event, error) {
+	events := make([]*Event, 0, len(stored))
+	for _, s := range stored {
+		if pattern != "" && !MatchTopic(pattern, s.Topic) {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(s.Data, &event); err != nil {
+			return nil, err
+		}
+		event.Offset = s.Offset
+		event.Sequence = uint64(s.ID)
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+// replayPageSize 回放时每次读取的事件数
+const replayPageSize = 500
+
+// catchUp 分页回放客户端错过的事件，追上最新事件后注册客户端。前面的页在锁外读取并由 write 写入，
+// 最后一次读取与 register 在 replayGate 写锁和 b.mu 内完成，此时没有已持久化但尚未入队的事件，
+// 读取结果只包含回放期间新发布的事件，由调用方在注册后写入，
+// 此后发布的事件进入客户端队列，因此回放与实时推送之间不丢失也不重复。
+// fetch 从上次读取的位置继续读取最多 limit 条事件（limit <= 0 表示不限制），more 为 true 表示可能还有更多
+func (b *Broker) catchUp(fetch func(limit int) (events []*Event, more bool, err error), write func(*Event) error, register func()) ([]*Event, error) {
+	for more := true; more; {
+		var (
+			events []*Event
+			err    error
+		)
+		events, more, err = fetch(replayPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if err := write(event); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b.replayGate.Lock()
+	defer b.replayGate.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tail, _, err := fetch(0)
+	if err != nil {
+		return nil, err
+	}
+	register()
+	return tail, nil
+}
@@ -0,0 +1,255 @@
+package event
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建内存中的 SQLite 数据库，每个测试独立
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// 单连接避免 SQLite 的写锁冲突
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+// newTestBroker 创建使用内存数据库并已迁移数据表的 Broker
+func newTestBroker(t *testing.T, opts ...Option) *Broker {
+	t.Helper()
+	b := NewBroker(append([]Option{WithDB(newTestDB(t))}, opts...)...)
+	require.NoError(t, b.AutoMigrate())
+	return b
+}
+
+func TestBroker_AutoMigrate(t *testing.T) {
+	require.ErrorIs(t, NewBroker().AutoMigrate(), ErrNoDatabase)
+
+	b := newTestBroker(t)
+	m := b.db.Migrator()
+	for _, model := range []interface{}{&Topic{}, &Subscription{}, &StoredEvent{}, &DeadLetter{}, &ScheduledEvent{}} {
+		require.True(t, m.HasTable(model))
+	}
+	require.True(t, m.HasIndex(&StoredEvent{}, "idx_tenant_topic_offset"))
+
+	// 重复迁移不报错
+	require.NoError(t, b.AutoMigrate())
+}
+
+func TestBroker_PersistOffsets(t *testing.T) {
+	b := newTestBroker(t)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Publish(&Event{Topic: "orders"}))
+	}
+	require.NoError(t, b.Publish(&Event{Topic: "users"}))
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Payload: &Payload{TenantId: "acme"}}))
+
+	var stored []StoredEvent
+	require.NoError(t, b.db.Order("id").Find(&stored).Error)
+	require.Len(t, stored, 5)
+
+	// offset 在同一租户的同一主题内从 1 递增
+	offsets := make([]int64, 0, len(stored))
+	for _, s := range stored {
+		offsets = append(offsets, s.Offset)
+	}
+	require.Equal(t, []int64{1, 2, 3, 1, 1}, offsets)
+	require.Equal(t, "acme", stored[4].TenantId)
+}
+
+func TestBroker_Replay(t *testing.T) {
+	b := newTestBroker(t)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, b.Publish(&Event{Topic: "orders", Subject: fmt.Sprint(i)}))
+	}
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Payload: &Payload{TenantId: "acme"}}))
+
+	events, err := b.Replay("orders", 3, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, int64(3), events[0].Offset)
+	require.Equal(t, "2", events[0].Subject)
+	require.NotZero(t, events[0].Sequence)
+
+	events, err = b.Replay("orders", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, int64(2), events[1].Offset)
+
+	// 其他租户的事件互不可见
+	events, err = b.ReplayTenant("acme", "orders", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	events, next, more, err := b.replayAfter("", "ord*", 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, events)
+	require.Equal(t, uint64(5), next)
+	require.False(t, more)
+
+	// 分页时按读取的记录推进，与是否匹配无关
+	events, next, more, err = b.replayAfter("", "*", 2, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, uint64(4), next)
+	require.True(t, more)
+
+	_, err = NewBroker().Replay("orders", 1, 0)
+	require.ErrorIs(t, err, ErrNoDatabase)
+}
+
+func TestBroker_CatchUp(t *testing.T) {
+	b := newTestBroker(t)
+	const total = replayPageSize + 10
+	for i := 0; i < total; i++ {
+		require.NoError(t, b.persist(&Event{Topic: "orders"}))
+	}
+
+	var from int64 = 1
+	fetch := func(limit int) ([]*Event, bool, error) {
+		events, err := b.ReplayTenant("", "orders", from, limit)
+		if len(events) > 0 {
+			from = events[len(events)-1].Offset + 1
+		}
+		return events, limit > 0 && len(events) == limit, err
+	}
+
+	var written []int64
+	write := func(event *Event) error {
+		// 分页回放在锁外写入
+		require.True(t, b.mu.TryLock())
+		b.mu.Unlock()
+		written = append(written, event.Offset)
+		if len(written) == total {
+			require.NoError(t, b.persist(&Event{Topic: "orders"}))
+		}
+		return nil
+	}
+	registered := false
+	register := func() {
+		require.False(t, b.mu.TryLock())
+		registered = true
+	}
+
+	tail, err := b.catchUp(fetch, write, register)
+	require.NoError(t, err)
+	require.True(t, registered)
+	require.Len(t, written, total)
+	require.Equal(t, int64(1), written[0])
+	require.Equal(t, int64(total), written[total-1])
+
+	// 回放期间发布的事件在注册时读取，由调用方写入
+	require.Len(t, tail, 1)
+	require.Equal(t, int64(total+1), tail[0].Offset)
+}
+
+func TestBroker_HandleEventsReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	b := NewBroker(WithDB(newTestDB(t)))
+	require.NoError(t, BrokerRouter(r.Group(""), b))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Publish(&Event{Topic: "orders", Id: fmt.Sprint(i)}))
+	}
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events?topic=orders&from_offset=2", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// 先回放 offset >= 2 的事件，再推送连接后发布的事件
+	require.Eventually(t, func() bool { return len(b.Connections("")) == 1 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "3"}))
+	for _, want := range []int64{2, 3, 4} {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, want, event.Offset)
+	}
+}
+
+func TestBroker_PersistSharedDB(t *testing.T) {
+	// 两个实例通过不同的连接共享同一个数据库文件
+	dsn := "file:" + filepath.Join(t.TempDir(), "events.db") + "?_journal_mode=WAL&_busy_timeout=5000"
+	brokers := make([]*Broker, 2)
+	for i := range brokers {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+		brokers[i] = NewBroker(WithDB(db))
+	}
+	require.NoError(t, brokers[0].AutoMigrate())
+
+	// 第一个实例读取 offset 后、写入前，另一个实例先写入了同一个 offset
+	var once sync.Once
+	require.NoError(t, brokers[0].db.Callback().Create().Before("gorm:create").Register("test:race", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Model.(*StoredEvent); ok {
+			once.Do(func() {
+				require.NoError(t, brokers[1].Publish(&Event{Topic: "orders", Id: "other"}))
+			})
+		}
+	}))
+
+	event := &Event{Topic: "orders", Id: "mine"}
+	require.NoError(t, brokers[0].Publish(event))
+	require.Equal(t, int64(2), event.Offset)
+
+	events, err := brokers[0].Replay("orders", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "other", events[0].Id)
+	require.Equal(t, "mine", events[1].Id)
+}
+
+func TestBroker_PersistOutsideLock(t *testing.T) {
+	b := newTestBroker(t)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	require.NoError(t, b.db.Callback().Create().Before("gorm:create").Register("test:block", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Model.(*StoredEvent); ok {
+			once.Do(func() {
+				close(entered)
+				<-release
+			})
+		}
+	}))
+
+	published := make(chan error)
+	go func() { published <- b.Publish(&Event{Topic: "orders"}) }()
+	<-entered
+
+	// 写入数据库期间不持有 Broker 的锁
+	require.True(t, b.mu.TryLock())
+	b.mu.Unlock()
+	b.Subscribe("users", func(*Event) {})
+	require.Empty(t, b.Connections(""))
+
+	close(release)
+	require.NoError(t, <-published)
+}
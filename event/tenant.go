@@ -0,0 +1,194 @@
+package event
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TenantHeader 未开启认证或调用方被授予 Principal.CrossTenant 时，用于指定租户的请求头
+const TenantHeader = "X-Tenant-Id"
+
+var (
+	// ErrQuotaExceeded 租户超出发布速率或连接数配额
+	ErrQuotaExceeded = errors.New("event: tenant quota exceeded")
+	// ErrTenantMismatch 事件的租户与调用方所属租户不一致
+	ErrTenantMismatch = errors.New("event: tenant mismatch")
+)
+
+// TenantQuota 租户配额，字段为 0 表示不限制
+type TenantQuota struct {
+	PublishRate    float64 `toml:"publishRate"`    // 每秒允许发布的事件数
+	PublishBurst   int     `toml:"publishBurst"`   // 允许的突发发布数，为 0 时取 PublishRate 向上取整
+	MaxConnections int     `toml:"maxConnections"` // WebSocket 和 SSE 连接数上限
+}
+
+// tokenBucket 令牌桶限流器
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (tb *tokenBucket) allow(now time.Time) bool {
+	tb.tokens = math.Min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// tenants 租户配额及用量
+type tenants struct {
+	mu          sync.Mutex
+	quota       TenantQuota            // 默认配额
+	quotas      map[string]TenantQuota // 单独配置的租户配额
+	buckets     map[string]*tokenBucket
+	connections map[string]int
+}
+
+// quotaOf 返回租户的配额
+func (t *tenants) quotaOf(tenant string) TenantQuota {
+	if q, ok := t.quotas[tenant]; ok {
+		return q
+	}
+	return t.quota
+}
+
+// allowPublish 按租户的发布速率配额限流
+func (t *tenants) allowPublish(tenant string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q := t.quotaOf(tenant)
+	if q.PublishRate <= 0 {
+		return true
+	}
+
+	tb, ok := t.buckets[tenant]
+	if !ok {
+		burst := float64(q.PublishBurst)
+		if burst <= 0 {
+			burst = math.Ceil(q.PublishRate)
+		}
+		tb = &tokenBucket{rate: q.PublishRate, burst: burst, tokens: burst, last: time.Now()}
+		if t.buckets == nil {
+			t.buckets = make(map[string]*tokenBucket)
+		}
+		t.buckets[tenant] = tb
+	}
+	return tb.allow(time.Now())
+}
+
+// acquireConn 占用租户的一个连接名额
+func (t *tenants) acquireConn(tenant string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q := t.quotaOf(tenant)
+	if q.MaxConnections > 0 && t.connections[tenant] >= q.MaxConnections {
+		return false
+	}
+	if t.connections == nil {
+		t.connections = make(map[string]int)
+	}
+	t.connections[tenant]++
+	return true
+}
+
+// releaseConn 释放租户的一个连接名额
+func (t *tenants) releaseConn(tenant string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.connections[tenant] > 0 {
+		t.connections[tenant]--
+	}
+}
+
+// acquireConn 为请求所属租户占用一个连接名额，超出配额时写入 429 响应
+func (b *Broker) acquireConn(c *gin.Context, tenant string) bool {
+	if b.tenants.acquireConn(tenant) {
+		return true
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many connections", "tenant": tenant})
+	return false
+}
+
+// tenantOf 返回事件所属租户，未指定时为默认租户 ""
+func tenantOf(event *Event) string {
+	if event.Payload == nil {
+		return ""
+	}
+	return event.Payload.TenantId
+}
+
+// requestTenant 返回请求所属租户：调用方属于某个租户时使用该租户；
+// 开启认证时不属于任何租户的调用方使用默认租户，除非被授予 CrossTenant；
+// 未开启认证或被授予 CrossTenant 时使用 TenantHeader 或 tenant 参数
+func requestTenant(c *gin.Context) string {
+	if p := PrincipalFrom(c); p != nil {
+		if p.TenantId != "" {
+			return p.TenantId
+		}
+		if !p.CrossTenant {
+			return ""
+		}
+	}
+	if tenant := c.GetHeader(TenantHeader); tenant != "" {
+		return tenant
+	}
+	return c.Query("tenant")
+}
+
+// bindTenant 将事件归属到 tenant：事件未指定租户时补全，指定了其他租户时返回 ErrTenantMismatch
+func bindTenant(tenant string, event *Event) error {
+	if tenant == "" {
+		return nil
+	}
+	if event.Payload == nil {
+		event.Payload = &Payload{}
+	}
+	if event.Payload.TenantId == "" {
+		event.Payload.TenantId = tenant
+	}
+	if event.Payload.TenantId != tenant {
+		return ErrTenantMismatch
+	}
+	return nil
+}
+
+// dropLegacyIndexes 删除引入租户前不含 tenant_id 的唯一索引，否则不同租户的数据会互相冲突
+func dropLegacyIndexes(db *gorm.DB) error {
+	m := db.Migrator()
+	for _, legacy := range []struct {
+		model interface{}
+		name  string
+	}{
+		{&StoredEvent{}, "idx_topic_offset"},
+		{&Subscription{}, "idx_topic_client_id"},
+	} {
+		if m.HasIndex(legacy.model, legacy.name) {
+			if err := m.DropIndex(legacy.model, legacy.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&Topic{}); err != nil {
+		return err
+	}
+	name := "uni_" + stmt.Schema.Table + "_name"
+	if m.HasConstraint(&Topic{}, name) {
+		return m.DropConstraint(&Topic{}, name)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_TenantIsolation(t *testing.T) {
+	b := NewBroker()
+
+	got := make(chan *Event, 4)
+	b.SubscribeTenant("t1", "orders.#", func(e *Event) { got <- e })
+
+	b.mu.Lock()
+	require.True(t, b.tenants.acquireConn("t2"))
+	cli := b.addClient(nil, "test", nil, "t2", "orders.#")
+	b.mu.Unlock()
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders.created", Payload: &Payload{TenantId: "t2"}}))
+	require.NoError(t, b.Publish(&Event{Topic: "orders.created", Payload: &Payload{TenantId: "t1"}}))
+
+	select {
+	case e := <-got:
+		require.Equal(t, "t1", e.Payload.TenantId)
+	case <-time.After(time.Second):
+		t.Fatal("tenant listener did not receive event")
+	}
+	require.Len(t, cli.out.ch, 1)
+	require.Equal(t, "t2", (<-cli.out.ch).Payload.TenantId)
+
+	b.removeClient(cli)
+	require.Zero(t, b.tenants.connections["t2"])
+}
+
+func TestBroker_TenantQuota(t *testing.T) {
+	b := NewBroker(
+		WithTenantQuota(TenantQuota{MaxConnections: 1}),
+		WithTenantQuotas(map[string]TenantQuota{"t1": {PublishRate: 1, PublishBurst: 2}}),
+	)
+
+	event := func() *Event { return &Event{Topic: "orders", Payload: &Payload{TenantId: "t1"}} }
+	require.NoError(t, b.Publish(event()))
+	require.NoError(t, b.Publish(event()))
+	require.ErrorIs(t, b.Publish(event()), ErrQuotaExceeded)
+	require.NoError(t, b.Publish(&Event{Topic: "orders"}))
+
+	require.True(t, b.tenants.acquireConn("t2"))
+	require.False(t, b.tenants.acquireConn("t2"))
+	b.tenants.releaseConn("t2")
+	require.True(t, b.tenants.acquireConn("t2"))
+}
+
+func TestBindTenant(t *testing.T) {
+	event := &Event{Topic: "orders"}
+	require.NoError(t, bindTenant("t1", event))
+	require.Equal(t, "t1", event.Payload.TenantId)
+	require.ErrorIs(t, bindTenant("t2", event), ErrTenantMismatch)
+	require.NoError(t, bindTenant("", &Event{}))
+}
+
+func TestBroker_RequestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	principals := map[string]*Principal{
+		"tenant":   {Id: "a", TenantId: "t1"},
+		"none":     {Id: "b"},
+		"operator": {Id: "c", CrossTenant: true},
+	}
+	auth := AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return principals[r.Header.Get("X-Test-Principal")], nil
+	})
+	b := NewBroker(WithAuth(auth))
+	r := gin.New()
+	require.NoError(t, BrokerRouter(r.Group(""), b))
+
+	got := make(chan *Event, 1)
+	b.Subscribe("orders", func(e *Event) { got <- e })
+
+	for principal, want := range map[string]string{"tenant": "t1", "none": "", "operator": "t2"} {
+		req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(`{"topic":"orders"}`))
+		req.Header.Set("X-Test-Principal", principal)
+		// 只有被授予 CrossTenant 的调用方可以通过请求头选择租户
+		req.Header.Set(TenantHeader, "t2")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, principal)
+		require.Equal(t, want, tenantOf(<-got), principal)
+	}
+}
@@ -2,9 +2,12 @@ package event
 
 import "gorm.io/gorm"
 
-// Topic 模型
+// Topic 模型，Schema 为可选的 JSON Schema，用于校验发布到该主题的事件 Detail。
+// 主题名在租户内唯一，TenantId 为空表示默认租户
 type Topic struct {
 	gorm.Model
-	Name        string `gorm:"unique;not null"`
+	TenantId    string `gorm:"uniqueIndex:idx_tenant_name;size:64;not null;default:''" json:"tenantId,omitempty"`
+	Name        string `gorm:"uniqueIndex:idx_tenant_name;size:255;not null"`
 	Description string
+	Schema      string `gorm:"type:text" json:"schema,omitempty"`
 }
@@ -13,6 +13,11 @@ func (b *Broker) handleCreateTopic(c *gin.Context) {
 		return
 	}
 
+	if !validTopicSchema(c, &topic) {
+		return
+	}
+
+	topic.TenantId = requestTenant(c)
 	if err := b.db.Create(&topic).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create topic"})
 		return
@@ -23,7 +28,7 @@ func (b *Broker) handleCreateTopic(c *gin.Context) {
 
 func (b *Broker) handleGetTopics(c *gin.Context) {
 	var topics []Topic
-	if err := b.db.Find(&topics).Error; err != nil {
+	if err := b.db.Where("tenant_id = ?", requestTenant(c)).Find(&topics).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch topics"})
 		return
 	}
@@ -39,7 +44,12 @@ func (b *Broker) handleUpdateTopic(c *gin.Context) {
 		return
 	}
 
-	result := b.db.First(&Topic{}, id)
+	if !validTopicSchema(c, &topic) {
+		return
+	}
+
+	tenant := requestTenant(c)
+	result := b.db.Where("tenant_id = ?", tenant).First(&Topic{}, id)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
 		return
@@ -47,6 +57,7 @@ func (b *Broker) handleUpdateTopic(c *gin.Context) {
 
 	i, _ := strconv.Atoi(id)
 	topic.ID = uint(i)
+	topic.TenantId = tenant
 	if err := b.db.Save(&topic).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic"})
 		return
@@ -58,7 +69,7 @@ func (b *Broker) handleUpdateTopic(c *gin.Context) {
 func (b *Broker) handleDeleteTopic(c *gin.Context) {
 	id := c.Param("id")
 
-	result := b.db.Delete(&Topic{}, id)
+	result := b.db.Where("tenant_id = ?", requestTenant(c)).Delete(&Topic{}, id)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete topic"})
 		return
@@ -71,3 +82,15 @@ func (b *Broker) handleDeleteTopic(c *gin.Context) {
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// validTopicSchema 校验主题的 JSON Schema 能否编译，失败时写入 400 响应
+func validTopicSchema(c *gin.Context, topic *Topic) bool {
+	if topic.Schema == "" {
+		return true
+	}
+	if _, err := compileSchema(topic.Name, topic.Schema); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schema", "detail": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package event
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// 传输类型
+const (
+	TransportMemory = "memory"
+	TransportKafka  = "kafka"
+)
+
+// Transport 事件传输，内存 Broker 和 Kafka 均实现该接口
+type Transport interface {
+	Publisher
+	Subscriber
+	Consumer
+	Shutdown(ctx context.Context) error
+}
+
+// TransportConfig 传输配置，服务通过配置在内存 Broker 和 Kafka 之间切换
+type TransportConfig struct {
+	Type  string       `toml:"type"` // "memory"（默认）或 "kafka"
+	Kafka *KafkaConfig `toml:"kafka"`
+}
+
+// NewTransport 根据配置创建传输，logger 为 nil 时使用 zap.L()。Kafka 传输配置了消费者组时会立即开始消费；
+// opts 只用于内存 Broker
+func NewTransport(cfg *TransportConfig, logger *zap.Logger, opts ...Option) (Transport, error) {
+	switch cfg.Type {
+	case "", TransportMemory:
+		return NewBroker(append([]Option{WithLogger(logger)}, opts...)...), nil
+	case TransportKafka:
+		if cfg.Kafka == nil {
+			return nil, fmt.Errorf("event: kafka transport requires kafka config")
+		}
+		k, err := NewKafka(cfg.Kafka, logger)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Kafka.GroupId != "" {
+			if err := k.Start(context.Background()); err != nil {
+				_ = k.Close()
+				return nil, err
+			}
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("event: unknown transport %q", cfg.Type)
+	}
+}
@@ -0,0 +1,253 @@
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// SignatureHeader 推送请求体的 HMAC-SHA256 签名，格式为 "sha256=<hex>"
+	SignatureHeader = "X-Event-Signature"
+	// EventIdHeader 推送事件的 Id
+	EventIdHeader = "X-Event-Id"
+	// EventTopicHeader 推送事件的主题
+	EventTopicHeader = "X-Event-Topic"
+)
+
+// RetryPolicy 重试策略，第 n 次重试前等待 InitialBackoff * 2^(n-1)，且不超过 MaxBackoff
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy 默认重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+// Backoff 返回第 attempt 次失败后的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := p.InitialBackoff << (attempt - 1)
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// DeadLetter 重试耗尽后仍未送达的推送记录
+type DeadLetter struct {
+	gorm.Model
+	SubscriptionId uint   `gorm:"index;not null"`
+	Topic          string `gorm:"index;size:255;not null"`
+	EventId        string `gorm:"size:64"`
+	CallbackUrl    string `gorm:"size:1024"`
+	Data           []byte
+	Attempts       int
+	LastError      string `gorm:"size:1024"`
+}
+
+// webhookIdleTimeout 推送协程空闲多久后退出，订阅有新事件时重新启动
+const webhookIdleTimeout = time.Minute
+
+// webhookWorker 一个 webhook 订阅的有界推送队列，由一个协程按发布顺序逐个推送
+type webhookWorker struct {
+	jobs chan webhookJob
+}
+
+type webhookJob struct {
+	sub   Subscription
+	event *Event
+}
+
+// Sign 使用 secret 计算 body 的 HMAC-SHA256 签名
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 校验 SignatureHeader 中的签名
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// enqueueWebhook 将事件放入订阅的推送队列，必要时启动推送协程。
+// 队列已满时不再推送，直接写入死信表
+func (b *Broker) enqueueWebhook(sub Subscription, event *Event) {
+	b.mu.Lock()
+	w, ok := b.webhooks[sub.ID]
+	if !ok {
+		w = &webhookWorker{jobs: make(chan webhookJob, b.queueOptions.Size)}
+		b.webhooks[sub.ID] = w
+		go b.runWebhook(sub.ID, w)
+	}
+	select {
+	case w.jobs <- webhookJob{sub: sub, event: event}:
+		b.inflight.Add(1)
+		b.mu.Unlock()
+		return
+	default:
+	}
+	b.mu.Unlock()
+
+	b.logger.Warn("Webhook queue full", zap.Uint("subscription", sub.ID), zap.String("id", event.Id))
+	_, body, err := encodeDelivery(sub, event)
+	if err != nil {
+		b.logger.Error("Failed to marshal event for subscriber", zap.Uint("subscription", sub.ID), zap.Error(err))
+		return
+	}
+	b.deadLetter(sub, event, body, 0, errors.New("webhook queue full"))
+}
+
+// runWebhook 订阅的推送协程，空闲超过 webhookIdleTimeout 或 Broker 关闭后退出
+func (b *Broker) runWebhook(id uint, w *webhookWorker) {
+	idle := time.NewTimer(webhookIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case job := <-w.jobs:
+			b.deliver(job.sub, job.event)
+			idle.Reset(webhookIdleTimeout)
+			continue
+		case <-idle.C:
+		case <-b.ctx.Done():
+		}
+
+		// 在锁内确认队列为空后注销，之后的事件会启动新的推送协程
+		b.mu.Lock()
+		if len(w.jobs) == 0 {
+			delete(b.webhooks, id)
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+		idle.Reset(webhookIdleTimeout)
+	}
+}
+
+// deliver 将事件推送到订阅的回调地址，失败时按重试策略退避重试，重试耗尽后写入死信表
+func (b *Broker) deliver(sub Subscription, event *Event) {
+	defer b.inflight.Add(-1)
+
+	header, body, err := encodeDelivery(sub, event)
+	if err != nil {
+		b.logger.Error("Failed to marshal event for subscriber", zap.Uint("subscription", sub.ID), zap.Error(err))
+		return
+	}
+
+	policy := b.retryPolicy
+	attempts := 0
+	for attempts < policy.MaxAttempts {
+		attempts++
+		if err = b.post(sub, header, body); err == nil {
+			b.countDelivery(event, pushed)
+			return
+		}
+		b.logger.Warn("Failed to push event",
+			zap.String("id", event.Id),
+			zap.String("url", sub.CallbackUrl),
+			zap.Int("attempt", attempts),
+			zap.Int("maxAttempts", policy.MaxAttempts),
+			zap.Error(err))
+		// 关闭时不再重试，直接写入死信表
+		if attempts < policy.MaxAttempts && !b.sleep(policy.Backoff(attempts)) {
+			break
+		}
+	}
+
+	b.deadLetter(sub, event, body, attempts, err)
+}
+
+// deadLetter 记录推送失败并写入死信表
+func (b *Broker) deadLetter(sub Subscription, event *Event, body []byte, attempts int, err error) {
+	b.countDelivery(event, dropped)
+
+	deadLetter := DeadLetter{
+		SubscriptionId: sub.ID,
+		Topic:          event.Topic,
+		EventId:        event.Id,
+		CallbackUrl:    sub.CallbackUrl,
+		Data:           body,
+		Attempts:       attempts,
+	}
+	if err != nil {
+		deadLetter.LastError = err.Error()
+		b.recordError("deliver", event, err)
+	}
+	if err := b.db.Create(&deadLetter).Error; err != nil {
+		b.logger.Error("Failed to save dead letter", zap.Uint("subscription", sub.ID), zap.Error(err))
+	}
+}
+
+// encodeDelivery 按订阅的投递格式编码推送请求的头和请求体，签名覆盖最终的请求体。
+// 各格式均不包含 Payload.Authorization
+func encodeDelivery(sub Subscription, event *Event) (http.Header, []byte, error) {
+	header := make(http.Header)
+
+	var (
+		body []byte
+		err  error
+	)
+	switch sub.Format {
+	case FormatCloudEvents:
+		body, err = MarshalCloudEvent(event)
+		header.Set("Content-Type", CloudEventsContentType)
+	case FormatCloudEventsBinary:
+		body, err = WriteBinaryCloudEvent(header, event)
+	default:
+		body, err = json.Marshal(withoutCredentials(event))
+		contentType := sub.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		header.Set("Content-Type", contentType)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header.Set(EventIdHeader, event.Id)
+	header.Set(EventTopicHeader, event.Topic)
+	if sub.Secret != "" {
+		header.Set(SignatureHeader, Sign(sub.Secret, body))
+	}
+	return header, body, nil
+}
+
+// post 发送一次推送请求，非 2xx 响应视为失败
+func (b *Broker) post(sub Subscription, header http.Header, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, sub.CallbackUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package event
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, p.Backoff(0))
+	require.Equal(t, time.Second, p.Backoff(1))
+	require.Equal(t, 4*time.Second, p.Backoff(3))
+	require.Equal(t, 5*time.Second, p.Backoff(4))
+	require.Equal(t, 5*time.Second, p.Backoff(100))
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", body)
+	require.Contains(t, signature, "sha256=")
+	require.True(t, VerifySignature("secret", body, signature))
+	require.False(t, VerifySignature("other", body, signature))
+	require.False(t, VerifySignature("secret", []byte(`{"id":"2"}`), signature))
+}
+
+func TestEncodeDelivery_StripsCredentials(t *testing.T) {
+	for _, format := range []string{FormatNative, FormatCloudEvents, FormatCloudEventsBinary} {
+		event := &Event{Topic: "orders", Id: "o-1", Payload: &Payload{Authorization: "Bearer secret", TenantId: "t1"}}
+		header, body, err := encodeDelivery(Subscription{Format: format}, event)
+		require.NoError(t, err)
+		require.NotContains(t, string(body), "secret", format)
+		for _, v := range header {
+			require.NotContains(t, v[0], "secret", format)
+		}
+		// 不修改进程内订阅者收到的事件
+		require.Equal(t, "Bearer secret", event.Payload.Authorization)
+	}
+}
+
+func TestBroker_Webhook(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature("secret", body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+	}))
+	defer srv.Close()
+
+	b := newTestBroker(t)
+	require.NoError(t, b.db.Create(&Subscription{Topic: "orders", Client: "billing", CallbackUrl: srv.URL, Secret: "secret"}).Error)
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "o-1"}))
+	select {
+	case r := <-received:
+		require.Equal(t, "o-1", r.Header.Get(EventIdHeader))
+		require.Equal(t, "orders", r.Header.Get(EventTopicHeader))
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	// 其他租户的订阅不会收到推送
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Payload: &Payload{TenantId: "acme"}}))
+	select {
+	case <-received:
+		t.Fatal("webhook delivered across tenants")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBroker_WebhookDeadLetter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	b := newTestBroker(t, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	sub := Subscription{Topic: "orders", Client: "billing", CallbackUrl: srv.URL}
+	require.NoError(t, b.db.Create(&sub).Error)
+
+	require.NoError(t, b.Publish(&Event{Topic: "orders", Id: "o-1"}))
+
+	var deadLetter DeadLetter
+	require.Eventually(t, func() bool {
+		return b.db.Where("event_id = ?", "o-1").First(&deadLetter).Error == nil
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(3), calls.Load())
+	require.Equal(t, sub.ID, deadLetter.SubscriptionId)
+	require.Equal(t, 3, deadLetter.Attempts)
+	require.Equal(t, srv.URL, deadLetter.CallbackUrl)
+	require.Contains(t, deadLetter.LastError, "502")
+	require.Len(t, b.RecentErrors(), 1)
+}
+
+func TestBroker_WebhookQueue(t *testing.T) {
+	release := make(chan struct{})
+	order := make(chan string, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		order <- r.Header.Get(EventIdHeader)
+	}))
+	defer srv.Close()
+
+	b := newTestBroker(t, WithBufferSize(2))
+	require.NoError(t, b.db.Create(&Subscription{Topic: "orders", Client: "billing", CallbackUrl: srv.URL}).Error)
+
+	// 第一个事件正在推送，队列容纳两个，第四个写入死信表
+	for _, id := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, b.Publish(&Event{Topic: "orders", Id: id}))
+		if id == "1" {
+			require.Eventually(t, func() bool {
+				b.mu.Lock()
+				defer b.mu.Unlock()
+				return len(b.webhooks[1].jobs) == 0
+			}, time.Second, 5*time.Millisecond)
+		}
+	}
+	var deadLetter DeadLetter
+	require.NoError(t, b.db.First(&deadLetter).Error)
+	require.Equal(t, "4", deadLetter.EventId)
+	require.Zero(t, deadLetter.Attempts)
+
+	// 同一订阅的事件按发布顺序逐个推送
+	close(release)
+	require.Eventually(t, func() bool { return b.inflight.Load() == 0 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, []string{"1", "2", "3"}, []string{<-order, <-order, <-order})
+}
@@ -1,61 +1,310 @@
 package eventbus
 
-import "sync"
+import (
+	"context"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Event 总线上的事件，Id、Time 和 Topic 在发布时自动填充
 type Event struct {
+	Id      string
+	Topic   string // 发布的主题，通配订阅者可据此区分事件
+	Time    time.Time
+	Headers map[string]string // 元数据，发布时注入发布方的 trace context
 	Payload []byte
 }
+
+// Header 返回元数据 key 的值
+func (e Event) Header(key string) string {
+	return e.Headers[key]
+}
+
+// Context 返回携带发布方 trace context 的 context，消费方可据此创建与发布方关联的 span
+func (e Event) Context() context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(e.Headers))
+}
+
 type (
 	EventChan chan Event
 )
 
 type EventBus struct {
 	mu          sync.RWMutex
-	subscribers map[string][]EventChan
+	subscribers map[string][]EventChan      // 精确主题的订阅
+	patterns    trie                        // 通配模式的订阅
+	subs        map[EventChan]*subscription // 订阅的队列配置
+	dropped     atomic.Int64
+	pending     chan struct{} // Publish 进行中的异步发布，容量为上限，nil 表示不限制
+
+	publishInterceptors interceptors[PublishInterceptor]
+	consumeInterceptors interceptors[ConsumeInterceptor]
 }
 
-func NewEventBus() *EventBus {
-	return &EventBus{
+// Option EventBus 配置项
+type Option func(*EventBus)
+
+// WithMaxPending 设置 Publish 允许同时进行的异步发布数，默认不限制。
+// 达到上限时 Publish 丢弃新事件并计入 Dropped，不会阻塞调用方
+func WithMaxPending(n int) Option {
+	return func(eb *EventBus) {
+		if n > 0 {
+			eb.pending = make(chan struct{}, n)
+		}
+	}
+}
+
+func NewEventBus(opts ...Option) *EventBus {
+	eb := &EventBus{
 		subscribers: map[string][]EventChan{},
+		subs:        map[EventChan]*subscription{},
 	}
+	for _, opt := range opts {
+		opt(eb)
+	}
+	return eb
 }
 
-// Subscribe 订阅
-func (eb *EventBus) Subscribe(topic string) EventChan {
+// Subscribe 订阅，默认返回无缓冲通道，发布时阻塞直到订阅者读取。
+// topic 可以是通配模式：按 "." 分段，"*" 匹配一段，末尾的 ">" 匹配其后的一段或多段，如 "user.*"、"user.>"
+func (eb *EventBus) Subscribe(topic string, opts ...SubscribeOption) EventChan {
+	sub := &subscription{done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	sub.ch = make(EventChan, sub.buffer)
+
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
-	ch := make(EventChan)
-	eb.subscribers[topic] = append(eb.subscribers[topic], ch)
-	return ch
+	if IsPattern(topic) {
+		eb.patterns.insert(topic, sub.ch)
+	} else {
+		eb.subscribers[topic] = append(eb.subscribers[topic], sub.ch)
+	}
+	eb.subs[sub.ch] = sub
+	return sub.ch
 }
 
-// Unsubscribe 取消订阅
+// Unsubscribe 取消订阅，topic 须与订阅时一致
 func (eb *EventBus) Unsubscribe(topic string, ch EventChan) {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
+	if IsPattern(topic) {
+		if eb.patterns.remove(topic, ch) {
+			eb.closeSubscription(ch)
+		}
+		return
+	}
 	if subscribers, ok := eb.subscribers[topic]; ok {
 		for i, subscriber := range subscribers {
 			if ch == subscriber {
 				eb.subscribers[topic] = append(subscribers[:i], subscribers[i+1:]...)
-				close(ch)
-				// 清空通道
-				for range ch {
-				}
+				eb.closeSubscription(ch)
 				return
 			}
 		}
 	}
 }
 
-// Publish 发布
+// closeSubscription 关闭已移除的订阅，调用方需持有 eb.mu
+func (eb *EventBus) closeSubscription(ch EventChan) {
+	sub := eb.subs[ch]
+	delete(eb.subs, ch)
+	sub.close()
+	// 清空通道
+	for range ch {
+	}
+}
+
+// Publish 异步发布，不等待订阅者接收，需要等待投递完成或取消时使用 PublishContext。
+// 进行中的异步发布达到 WithMaxPending 设置的上限时丢弃该事件，处理函数中发布到自身订阅的主题也不会阻塞
 func (eb *EventBus) Publish(topic string, event Event) {
-	eb.mu.RLock()
-	defer eb.mu.RUnlock()
-	// 复制一个新的订阅者列表，避免在发布事件时修改订阅者列表
-	subscribers := append([]EventChan{}, eb.subscribers[topic]...)
+	if eb.pending != nil {
+		select {
+		case eb.pending <- struct{}{}:
+		default:
+			eb.dropped.Add(1)
+			return
+		}
+	}
 	go func() {
-		for _, subscriber := range subscribers {
-			subscriber <- event
+		if eb.pending != nil {
+			defer func() { <-eb.pending }()
 		}
+		_ = eb.PublishContext(context.Background(), topic, event)
 	}()
 }
+
+// PublishContext 同步发布，依次经过发布拦截器后按各订阅的溢出策略投递。
+// 阻塞策略的订阅者未及时读取时等待，ctx 结束时停止投递并返回 ctx.Err()；
+// ctx 中的 trace context 写入事件的 Headers
+func (eb *EventBus) PublishContext(ctx context.Context, topic string, event Event) error {
+	if event.Id == "" {
+		event.Id = uuid.NewString()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Topic = topic
+	// 复制 Headers，避免修改调用方的 map
+	headers := make(map[string]string, len(event.Headers)+2)
+	maps.Copy(headers, event.Headers)
+	event.Headers = headers
+
+	return eb.publishChain(topic, eb.deliver)(ctx, topic, event)
+}
+
+// deliver 注入 trace context 后投递给匹配的订阅者，是发布拦截器链的最内层
+func (eb *EventBus) deliver(ctx context.Context, topic string, event Event) error {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(event.Headers))
+
+	eb.mu.RLock()
+	// 复制一个新的订阅者列表，避免在发布事件时修改订阅者列表
+	subs := make([]*subscription, 0, len(eb.subscribers[topic]))
+	for _, ch := range eb.subscribers[topic] {
+		subs = append(subs, eb.subs[ch])
+	}
+	for _, ch := range eb.patterns.match(topic) {
+		subs = append(subs, eb.subs[ch])
+	}
+	eb.mu.RUnlock()
+
+	for _, sub := range subs {
+		dropped, err := sub.send(ctx, event)
+		eb.dropped.Add(dropped)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dropped 返回因订阅者队列已满、等待超时或异步发布数达到上限而丢弃的事件数
+func (eb *EventBus) Dropped() int64 {
+	return eb.dropped.Load()
+}
+
+// OverflowPolicy 订阅者队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// Block 等待订阅者读取，可通过 WithBlockTimeout 限制等待时间
+	Block OverflowPolicy = iota
+	// DropNewest 丢弃新到达的事件
+	DropNewest
+	// DropOldest 丢弃队列中最早的事件，为新事件腾出位置
+	DropOldest
+)
+
+// SubscribeOption 订阅配置项
+type SubscribeOption func(*subscription)
+
+// WithBufferSize 设置订阅通道的容量，默认为 0（无缓冲）
+func WithBufferSize(size int) SubscribeOption {
+	return func(s *subscription) {
+		if size > 0 {
+			s.buffer = size
+		}
+	}
+}
+
+// WithOverflow 设置队列已满时的处理策略，默认为 Block
+func WithOverflow(policy OverflowPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = policy
+	}
+}
+
+// WithBlockTimeout 设置 Block 策略的最长等待时间，超时后丢弃该事件
+func WithBlockTimeout(timeout time.Duration) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = Block
+		s.timeout = timeout
+	}
+}
+
+// subscription 订阅的通道及其队列配置
+type subscription struct {
+	ch      EventChan
+	buffer  int
+	policy  OverflowPolicy
+	timeout time.Duration
+	workers int                                            // SubscribeFunc 的工作协程数
+	onPanic func(topic string, event Event, v interface{}) // SubscribeFunc 处理函数 panic 时的回调
+
+	mu     sync.RWMutex  // 发送时持有读锁，关闭通道时持有写锁
+	done   chan struct{} // 取消订阅时关闭，中断等待中的发送
+	closed bool
+}
+
+// send 按溢出策略投递事件，返回丢弃的事件数，包括未能放入通道的新事件和为其腾出位置的旧事件。
+// 每个订阅收到各自的 Headers 副本，订阅者修改 Headers 不影响其他订阅者
+func (s *subscription) send(ctx context.Context, event Event) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return 1, nil
+	}
+	event.Headers = maps.Clone(event.Headers)
+
+	select {
+	case s.ch <- event:
+		return 0, nil
+	default:
+	}
+
+	switch s.policy {
+	case DropNewest:
+		return 1, nil
+	case DropOldest:
+		// 无缓冲通道没有可丢弃的旧事件
+		if cap(s.ch) == 0 {
+			return 1, nil
+		}
+		var evicted int64
+		for {
+			select {
+			case <-s.ch:
+				evicted++
+			default:
+			}
+			select {
+			case s.ch <- event:
+				return evicted, nil
+			default:
+			}
+		}
+	}
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case s.ch <- event:
+		return 0, nil
+	case <-timeout:
+		return 1, nil
+	case <-s.done:
+		return 1, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// close 中断等待中的发送并关闭通道
+func (s *subscription) close() {
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.ch)
+}
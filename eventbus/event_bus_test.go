@@ -1,8 +1,11 @@
 package eventbus
 
 import (
+	"context"
 	"github.com/stretchr/testify/require"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewEventBus(t *testing.T) {
@@ -34,3 +37,86 @@ func TestEventBus_Publish(t *testing.T) {
 	event := <-subscribe
 	require.Equal(t, "test", string(event.Payload))
 }
+
+func TestEventBus_Overflow(t *testing.T) {
+	eventBus := NewEventBus()
+	newest := eventBus.Subscribe("test", WithBufferSize(1), WithOverflow(DropNewest))
+	oldest := eventBus.Subscribe("test", WithBufferSize(1), WithOverflow(DropOldest))
+	timeout := eventBus.Subscribe("test", WithBlockTimeout(time.Millisecond))
+
+	ctx := context.Background()
+	require.NoError(t, eventBus.PublishContext(ctx, "test", Event{Payload: []byte("1")}))
+	require.NoError(t, eventBus.PublishContext(ctx, "test", Event{Payload: []byte("2")}))
+
+	require.Equal(t, "1", string((<-newest).Payload))
+	require.Equal(t, "2", string((<-oldest).Payload))
+	require.Len(t, timeout, 0)
+	// DropNewest 丢弃新事件，DropOldest 丢弃旧事件，阻塞订阅者两次超时
+	require.Equal(t, int64(4), eventBus.Dropped())
+}
+
+func TestEventBus_PublishContext(t *testing.T) {
+	eventBus := NewEventBus()
+	blocked := eventBus.Subscribe("test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, eventBus.PublishContext(ctx, "test", Event{}), context.DeadlineExceeded)
+
+	// 取消订阅会中断等待中的发布
+	done := make(chan error)
+	go func() {
+		done <- eventBus.PublishContext(context.Background(), "test", Event{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	eventBus.Unsubscribe("test", blocked)
+	require.NoError(t, <-done)
+}
+
+func TestEventBus_MaxPending(t *testing.T) {
+	eventBus := NewEventBus(WithMaxPending(1))
+	ch := eventBus.Subscribe("test")
+
+	// 第一个发布阻塞在订阅者上，达到上限后的发布被丢弃而不阻塞调用方
+	eventBus.Publish("test", Event{Payload: []byte("1")})
+	eventBus.Publish("test", Event{Payload: []byte("2")})
+	require.Equal(t, int64(1), eventBus.Dropped())
+	require.Equal(t, "1", string((<-ch).Payload))
+
+	// 发布完成后释放名额
+	require.Eventually(t, func() bool {
+		eventBus.Publish("test", Event{Payload: []byte("3")})
+		select {
+		case event := <-ch:
+			return string(event.Payload) == "3"
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+}
+
+func TestEventBus_PublishFromHandler(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithMaxPending(1)}} {
+		eventBus := NewEventBus(opts...)
+		var handled atomic.Int32
+		sub := eventBus.SubscribeFunc("a", func(ctx context.Context, event Event) {
+			if string(event.Payload) != "root" {
+				handled.Add(1)
+				return
+			}
+			// 处理函数发布到自身订阅的主题，不能阻塞工作协程
+			for i := 0; i < 1026; i++ {
+				eventBus.Publish("a", Event{})
+			}
+		})
+		require.NoError(t, eventBus.PublishContext(context.Background(), "a", Event{Payload: []byte("root")}))
+
+		want := int32(1026)
+		if opts != nil {
+			want = 1
+		}
+		require.Eventually(t, func() bool { return handled.Load() == want }, time.Second, time.Millisecond)
+		require.Equal(t, int64(1026-want), eventBus.Dropped())
+		sub.Unsubscribe()
+	}
+}
@@ -0,0 +1,87 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// HandlerFunc SubscribeFunc 的事件处理函数，ctx 携带发布方的 trace context 及消费拦截器写入的值
+type HandlerFunc func(ctx context.Context, event Event)
+
+// WithWorkers 设置 SubscribeFunc 并发调用处理函数的协程数，默认为 1，即按发布顺序依次处理
+func WithWorkers(n int) SubscribeOption {
+	return func(s *subscription) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// WithPanicHandler 设置处理函数 panic 时的回调，默认记录错误日志
+func WithPanicHandler(fn func(topic string, event Event, v interface{})) SubscribeOption {
+	return func(s *subscription) {
+		s.onPanic = fn
+	}
+}
+
+// FuncSubscription SubscribeFunc 返回的订阅
+type FuncSubscription struct {
+	bus   *EventBus
+	topic string
+	ch    EventChan
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// Unsubscribe 取消订阅并丢弃未处理的事件，不等待正在执行的处理函数，可在处理函数中调用；
+// 需要等待处理函数返回时在处理函数之外调用 Wait
+func (s *FuncSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.Unsubscribe(s.topic, s.ch)
+	})
+}
+
+// Wait 等待工作协程退出，即取消订阅后正在执行的处理函数全部返回，不能在处理函数中调用
+func (s *FuncSubscription) Wait() {
+	s.wg.Wait()
+}
+
+// SubscribeFunc 订阅 topic，由总线在工作协程中经消费拦截器调用 handler，处理函数 panic 不会影响其他事件
+func (eb *EventBus) SubscribeFunc(topic string, handler HandlerFunc, opts ...SubscribeOption) *FuncSubscription {
+	ch := eb.Subscribe(topic, opts...)
+
+	eb.mu.RLock()
+	sub := eb.subs[ch]
+	eb.mu.RUnlock()
+
+	fs := &FuncSubscription{bus: eb, topic: topic, ch: ch}
+	workers := max(sub.workers, 1)
+	fs.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer fs.wg.Done()
+			for event := range ch {
+				eb.consumeChain(event.Topic, func(ctx context.Context, event Event) {
+					sub.call(ctx, topic, handler, event)
+				})(event.Context(), event)
+			}
+		}()
+	}
+	return fs
+}
+
+// call 调用处理函数并恢复 panic
+func (s *subscription) call(ctx context.Context, topic string, handler HandlerFunc, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.onPanic != nil {
+				s.onPanic(topic, event, r)
+				return
+			}
+			zap.L().Error("Event handler panicked", zap.String("topic", topic), zap.Any("panic", r), zap.Stack("stack"))
+		}
+	}()
+	handler(ctx, event)
+}
@@ -0,0 +1,86 @@
+package eventbus
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus_SubscribeFunc(t *testing.T) {
+	eventBus := NewEventBus()
+
+	var (
+		running, peak atomic.Int64
+		handled       = make(chan string, 10)
+		panics        = make(chan interface{}, 1)
+	)
+	sub := eventBus.SubscribeFunc("test", func(_ context.Context, event Event) {
+		if string(event.Payload) == "bad" {
+			panic("boom")
+		}
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		handled <- string(event.Payload)
+	}, WithWorkers(2), WithBufferSize(10), WithPanicHandler(func(topic string, event Event, v interface{}) {
+		panics <- v
+	}))
+
+	ctx := context.Background()
+	require.NoError(t, eventBus.PublishContext(ctx, "test", Event{Payload: []byte("bad")}))
+	for _, payload := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, eventBus.PublishContext(ctx, "test", Event{Payload: []byte(payload)}))
+	}
+
+	require.Equal(t, "boom", <-panics)
+	for i := 0; i < 4; i++ {
+		<-handled
+	}
+	require.Equal(t, int64(2), peak.Load())
+
+	sub.Unsubscribe()
+	sub.Wait()
+	require.Empty(t, eventBus.subscribers["test"])
+	sub.Unsubscribe()
+}
+
+func TestFuncSubscription_UnsubscribeFromHandler(t *testing.T) {
+	eventBus := NewEventBus()
+
+	var handled atomic.Int32
+	var sub *FuncSubscription
+	subscribed := make(chan struct{})
+	sub = eventBus.SubscribeFunc("test", func(_ context.Context, event Event) {
+		<-subscribed
+		handled.Add(1)
+		// 处理第一个事件后取消订阅
+		sub.Unsubscribe()
+	}, WithBufferSize(2))
+	close(subscribed)
+
+	ctx := context.Background()
+	require.NoError(t, eventBus.PublishContext(ctx, "test", Event{}))
+	require.NoError(t, eventBus.PublishContext(ctx, "test", Event{}))
+
+	done := make(chan struct{})
+	go func() {
+		sub.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe from handler deadlocked")
+	}
+	require.Equal(t, int32(1), handled.Load())
+	require.Empty(t, eventBus.subscribers["test"])
+}
@@ -0,0 +1,106 @@
+package eventbus
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// tracerName 默认 tracer 的名称
+const tracerName = "github.com/gagraler/pkg/eventbus"
+
+// PublishLogger 记录每次发布的主题、事件 Id、耗时和错误，logger 为 nil 时使用 zap.L()
+func PublishLogger(logger *zap.Logger) PublishInterceptor {
+	if logger == nil {
+		logger = zap.L()
+	}
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, event Event) error {
+			start := time.Now()
+			err := next(ctx, topic, event)
+			fields := []zap.Field{
+				zap.String("topic", topic),
+				zap.String("id", event.Id),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				logger.Error("Failed to publish event", append(fields, zap.Error(err))...)
+			} else {
+				logger.Debug("Event published", fields...)
+			}
+			return err
+		}
+	}
+}
+
+// ConsumeLogger 记录每次处理的主题、事件 Id 和耗时，logger 为 nil 时使用 zap.L()
+func ConsumeLogger(logger *zap.Logger) ConsumeInterceptor {
+	if logger == nil {
+		logger = zap.L()
+	}
+	return func(next ConsumeFunc) ConsumeFunc {
+		return func(ctx context.Context, event Event) {
+			start := time.Now()
+			next(ctx, event)
+			logger.Debug("Event consumed",
+				zap.String("topic", event.Topic),
+				zap.String("id", event.Id),
+				zap.Duration("duration", time.Since(start)))
+		}
+	}
+}
+
+// PublishTracer 为每次发布创建 producer span，span 的 trace context 随事件 Headers 传给消费方。
+// tracer 为 nil 时使用全局 TracerProvider
+func PublishTracer(tracer trace.Tracer) PublishInterceptor {
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, event Event) error {
+			ctx, span := tracer.Start(ctx, "publish "+topic,
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(spanAttributes(topic, event)...))
+			defer span.End()
+
+			err := next(ctx, topic, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// ConsumeTracer 为每次处理创建 consumer span，父 span 为发布方的 producer span。
+// tracer 为 nil 时使用全局 TracerProvider
+func ConsumeTracer(tracer trace.Tracer) ConsumeInterceptor {
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return func(next ConsumeFunc) ConsumeFunc {
+		return func(ctx context.Context, event Event) {
+			ctx, span := tracer.Start(ctx, "process "+event.Topic,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(spanAttributes(event.Topic, event)...))
+			defer span.End()
+			next(ctx, event)
+		}
+	}
+}
+
+// spanAttributes 按 OpenTelemetry messaging 语义约定生成 span 属性
+func spanAttributes(topic string, event Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "eventbus"),
+		attribute.String("messaging.destination.name", topic),
+		attribute.String("messaging.message.id", event.Id),
+		attribute.Int("messaging.message.body.size", len(event.Payload)),
+	}
+}
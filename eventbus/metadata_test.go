@@ -0,0 +1,50 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEventBus_Metadata(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracer := sdktrace.NewTracerProvider().Tracer("eventbus")
+
+	eventBus := NewEventBus()
+	ch := eventBus.Subscribe("user.>", WithBufferSize(1))
+
+	ctx, span := tracer.Start(context.Background(), "publish")
+	headers := map[string]string{"source": "test"}
+	require.NoError(t, eventBus.PublishContext(ctx, "user.created", Event{Headers: headers, Payload: []byte("1")}))
+	span.End()
+
+	event := <-ch
+	require.NotEmpty(t, event.Id)
+	require.False(t, event.Time.IsZero())
+	require.Equal(t, "user.created", event.Topic)
+	require.Equal(t, "test", event.Header("source"))
+	require.NotEmpty(t, event.Header("traceparent"))
+	require.Len(t, headers, 1)
+
+	remote := trace.SpanContextFromContext(event.Context())
+	require.True(t, remote.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+}
+
+func TestEventBus_HeadersPerSubscription(t *testing.T) {
+	eventBus := NewEventBus()
+	a := eventBus.Subscribe("user.created", WithBufferSize(1))
+	b := eventBus.Subscribe("user.*", WithBufferSize(1))
+
+	require.NoError(t, eventBus.PublishContext(context.Background(), "user.created", Event{Headers: map[string]string{"source": "test"}}))
+
+	// 修改收到的 Headers 不影响其他订阅者
+	first := <-a
+	first.Headers["source"] = "changed"
+	require.Equal(t, "test", (<-b).Header("source"))
+}
@@ -0,0 +1,109 @@
+package eventbus
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// PublishFunc 发布事件
+type PublishFunc func(ctx context.Context, topic string, event Event) error
+
+// PublishInterceptor 发布拦截器，可在调用 next 前后执行逻辑，或不调用 next 以拒绝发布
+type PublishInterceptor func(next PublishFunc) PublishFunc
+
+// ConsumeFunc 处理事件，ctx 携带发布方的 trace context
+type ConsumeFunc func(ctx context.Context, event Event)
+
+// ConsumeInterceptor 消费拦截器，作用于 SubscribeFunc 的处理函数及 WrapHandler 包装的处理函数
+type ConsumeInterceptor func(next ConsumeFunc) ConsumeFunc
+
+// interceptors 全局及按主题模式注册的拦截器，由 eb.mu 保护
+type interceptors[T any] struct {
+	global []T
+	topics []topicInterceptor[T]
+}
+
+type topicInterceptor[T any] struct {
+	pattern     string
+	interceptor T
+}
+
+// resolve 按注册顺序返回作用于 topic 的拦截器，全局拦截器在前
+func (i *interceptors[T]) resolve(topic string) []T {
+	resolved := append([]T{}, i.global...)
+	for _, t := range i.topics {
+		if MatchTopic(t.pattern, topic) {
+			resolved = append(resolved, t.interceptor)
+		}
+	}
+	return resolved
+}
+
+// UsePublish 注册作用于所有主题的发布拦截器，先注册的位于外层
+func (eb *EventBus) UsePublish(interceptors ...PublishInterceptor) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.publishInterceptors.global = append(eb.publishInterceptors.global, interceptors...)
+}
+
+// UsePublishFor 注册作用于匹配 pattern 的主题的发布拦截器，pattern 语法见 Subscribe
+func (eb *EventBus) UsePublishFor(pattern string, interceptors ...PublishInterceptor) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	for _, interceptor := range interceptors {
+		eb.publishInterceptors.topics = append(eb.publishInterceptors.topics, topicInterceptor[PublishInterceptor]{pattern, interceptor})
+	}
+}
+
+// UseConsume 注册作用于所有主题的消费拦截器，先注册的位于外层
+func (eb *EventBus) UseConsume(interceptors ...ConsumeInterceptor) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.consumeInterceptors.global = append(eb.consumeInterceptors.global, interceptors...)
+}
+
+// UseConsumeFor 注册作用于匹配 pattern 的主题的消费拦截器，pattern 语法见 Subscribe
+func (eb *EventBus) UseConsumeFor(pattern string, interceptors ...ConsumeInterceptor) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	for _, interceptor := range interceptors {
+		eb.consumeInterceptors.topics = append(eb.consumeInterceptors.topics, topicInterceptor[ConsumeInterceptor]{pattern, interceptor})
+	}
+}
+
+// publishChain 以 final 为最内层组装 topic 的发布拦截器链
+func (eb *EventBus) publishChain(topic string, final PublishFunc) PublishFunc {
+	eb.mu.RLock()
+	chain := eb.publishInterceptors.resolve(topic)
+	eb.mu.RUnlock()
+
+	next := final
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+	return next
+}
+
+// consumeChain 以 final 为最内层组装 topic 的消费拦截器链
+func (eb *EventBus) consumeChain(topic string, final ConsumeFunc) ConsumeFunc {
+	eb.mu.RLock()
+	chain := eb.consumeInterceptors.resolve(topic)
+	eb.mu.RUnlock()
+
+	next := final
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+	return next
+}
+
+// WrapHandler 为自行读取 EventChan 的订阅者应用消费拦截器，按事件的主题选择拦截器。
+// 返回的处理函数在调用方的 ctx 中加入事件携带的 trace context 后经过拦截器
+func (eb *EventBus) WrapHandler(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, event Event) {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.Headers))
+		eb.consumeChain(event.Topic, ConsumeFunc(handler))(ctx, event)
+	}
+}
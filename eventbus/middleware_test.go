@@ -0,0 +1,97 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestEventBus_Interceptors(t *testing.T) {
+	eventBus := NewEventBus()
+
+	var calls []string
+	record := func(name string) PublishInterceptor {
+		return func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, topic string, event Event) error {
+				calls = append(calls, name)
+				return next(ctx, topic, event)
+			}
+		}
+	}
+	errRejected := errors.New("rejected")
+	eventBus.UsePublish(record("global"), PublishLogger(zap.NewNop()))
+	eventBus.UsePublishFor("user.*", record("user"))
+	eventBus.UsePublishFor("admin.>", func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, event Event) error {
+			return errRejected
+		}
+	})
+
+	ch := eventBus.Subscribe(">", WithBufferSize(4))
+	ctx := context.Background()
+	require.NoError(t, eventBus.PublishContext(ctx, "user.created", Event{}))
+	require.NoError(t, eventBus.PublishContext(ctx, "order.created", Event{}))
+	require.ErrorIs(t, eventBus.PublishContext(ctx, "admin.deleted", Event{}), errRejected)
+	require.Equal(t, []string{"global", "user", "global", "global"}, calls)
+	require.Len(t, ch, 2)
+
+	type ctxKey struct{}
+	var consumed []string
+	eventBus.UseConsumeFor("user.*", func(next ConsumeFunc) ConsumeFunc {
+		return func(ctx context.Context, event Event) {
+			consumed = append(consumed, "interceptor")
+			next(context.WithValue(ctx, ctxKey{}, "intercepted"), event)
+		}
+	})
+	handler := eventBus.WrapHandler(func(ctx context.Context, event Event) {
+		consumed = append(consumed, event.Topic)
+		if v, ok := ctx.Value(ctxKey{}).(string); ok {
+			consumed = append(consumed, v)
+		}
+	})
+	handler(context.Background(), <-ch)
+	handler(context.Background(), <-ch)
+	// 拦截器写入 ctx 的值传递给处理函数
+	require.Equal(t, []string{"interceptor", "user.created", "intercepted", "order.created"}, consumed)
+}
+
+func TestEventBus_TracingInterceptors(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	eventBus := NewEventBus()
+	eventBus.UsePublish(PublishTracer(tracer))
+	eventBus.UseConsume(ConsumeTracer(tracer), ConsumeLogger(zap.NewNop()))
+
+	done := make(chan trace.SpanContext, 1)
+	sub := eventBus.SubscribeFunc("user.created", func(ctx context.Context, _ Event) {
+		done <- trace.SpanContextFromContext(ctx)
+	}, WithBufferSize(1))
+	defer sub.Unsubscribe()
+
+	require.NoError(t, eventBus.PublishContext(context.Background(), "user.created", Event{}))
+	handlerSpan := <-done
+	sub.Unsubscribe()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	producer, consumer := spans[0], spans[1]
+	if producer.SpanKind() != trace.SpanKindProducer {
+		producer, consumer = consumer, producer
+	}
+	require.Equal(t, "publish user.created", producer.Name())
+	require.Equal(t, "process user.created", consumer.Name())
+	require.Equal(t, producer.SpanContext().SpanID(), consumer.Parent().SpanID())
+	require.Equal(t, producer.SpanContext().TraceID(), consumer.SpanContext().TraceID())
+	// 处理函数在消费 span 中执行
+	require.Equal(t, consumer.SpanContext().SpanID(), handlerSpan.SpanID())
+}
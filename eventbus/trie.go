@@ -0,0 +1,113 @@
+package eventbus
+
+import "strings"
+
+// 主题按 "." 分段，模式中 "*" 匹配一段，">" 只能位于末尾，匹配其后的一段或多段
+const (
+	wildcardOne  = "*"
+	wildcardRest = ">"
+)
+
+// IsPattern 判断主题是否为通配模式
+func IsPattern(topic string) bool {
+	for _, segment := range strings.Split(topic, ".") {
+		if segment == wildcardOne || segment == wildcardRest {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTopic 判断主题是否匹配模式
+func MatchTopic(pattern, topic string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(topic, "."))
+}
+
+func matchSegments(pattern, topic []string) bool {
+	for i, segment := range pattern {
+		if segment == wildcardRest {
+			return i == len(pattern)-1 && len(topic) > i
+		}
+		if i >= len(topic) || (segment != wildcardOne && segment != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
+
+// trie 按主题分段索引的模式订阅，发布时只需遍历与主题相关的分支
+type trie struct {
+	root trieNode
+}
+
+type trieNode struct {
+	children    map[string]*trieNode
+	subscribers []EventChan
+}
+
+// insert 添加模式订阅
+func (t *trie) insert(pattern string, ch EventChan) {
+	node := &t.root
+	for _, segment := range strings.Split(pattern, ".") {
+		if node.children == nil {
+			node.children = make(map[string]*trieNode)
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			child = &trieNode{}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.subscribers = append(node.subscribers, ch)
+}
+
+// remove 删除模式订阅，返回订阅是否存在
+func (t *trie) remove(pattern string, ch EventChan) bool {
+	return t.root.remove(strings.Split(pattern, "."), ch)
+}
+
+func (n *trieNode) remove(segments []string, ch EventChan) bool {
+	if len(segments) == 0 {
+		for i, subscriber := range n.subscribers {
+			if subscriber == ch {
+				n.subscribers = append(n.subscribers[:i], n.subscribers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	child, ok := n.children[segments[0]]
+	if !ok || !child.remove(segments[1:], ch) {
+		return false
+	}
+	// 删除空分支
+	if len(child.subscribers) == 0 && len(child.children) == 0 {
+		delete(n.children, segments[0])
+	}
+	return true
+}
+
+// match 返回匹配主题的模式订阅
+func (t *trie) match(topic string) []EventChan {
+	var matched []EventChan
+	t.root.match(strings.Split(topic, "."), &matched)
+	return matched
+}
+
+func (n *trieNode) match(segments []string, matched *[]EventChan) {
+	if len(segments) == 0 {
+		*matched = append(*matched, n.subscribers...)
+		return
+	}
+	if child, ok := n.children[wildcardRest]; ok {
+		*matched = append(*matched, child.subscribers...)
+	}
+	if child, ok := n.children[segments[0]]; ok {
+		child.match(segments[1:], matched)
+	}
+	if child, ok := n.children[wildcardOne]; ok {
+		child.match(segments[1:], matched)
+	}
+}
@@ -0,0 +1,51 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"user.created", "user.created", true},
+		{"user.*", "user.created", true},
+		{"user.*", "user.created.v1", false},
+		{"user.*", "user", false},
+		{"a.*.c", "a.b.c", true},
+		{"a.*.c", "a.b.d", false},
+		{"user.>", "user.created", true},
+		{"user.>", "user.created.v1", true},
+		{"user.>", "user", false},
+		{">", "user", true},
+		{"user.>.v1", "user.created.v1", false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, MatchTopic(c.pattern, c.topic), "%s %s", c.pattern, c.topic)
+	}
+}
+
+func TestEventBus_PatternSubscribe(t *testing.T) {
+	eventBus := NewEventBus()
+	one := eventBus.Subscribe("user.*", WithBufferSize(4))
+	rest := eventBus.Subscribe("user.>", WithBufferSize(4))
+	middle := eventBus.Subscribe("a.*.c", WithBufferSize(4))
+	require.Empty(t, eventBus.subscribers)
+
+	ctx := context.Background()
+	for _, topic := range []string{"user.created", "user.created.v1", "a.b.c", "order.created"} {
+		require.NoError(t, eventBus.PublishContext(ctx, topic, Event{Payload: []byte(topic)}))
+	}
+	require.Len(t, one, 1)
+	require.Len(t, rest, 2)
+	require.Len(t, middle, 1)
+
+	eventBus.Unsubscribe("user.*", one)
+	eventBus.Unsubscribe("user.>", rest)
+	eventBus.Unsubscribe("a.*.c", middle)
+	require.Empty(t, eventBus.patterns.root.children)
+}
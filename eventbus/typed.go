@@ -0,0 +1,112 @@
+package eventbus
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Bus 在 EventBus 之上按类型 T 收发事件，载荷由 Codec 编解码
+type Bus[T any] struct {
+	bus     *EventBus
+	codec   Codec
+	onError func(topic string, err error)
+}
+
+// NewBus 创建类型为 T 的事件总线，codec 为 nil 时使用 JSONCodec。
+// 使用 ProtoCodec 时 T 应为生成的消息指针类型，如 *pb.Order
+func NewBus[T any](bus *EventBus, codec Codec) *Bus[T] {
+	if codec == nil {
+		codec = JSONCodec
+	}
+	return &Bus[T]{bus: bus, codec: codec}
+}
+
+// OnError 设置解码失败时的回调，默认丢弃无法解码的事件
+func (b *Bus[T]) OnError(fn func(topic string, err error)) *Bus[T] {
+	b.onError = fn
+	return b
+}
+
+// Publish 编码 v 并发布到 topic
+func (b *Bus[T]) Publish(topic string, v T) error {
+	data, err := b.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b.bus.Publish(topic, Event{Payload: data})
+	return nil
+}
+
+// Subscription 类型化订阅
+type Subscription[T any] struct {
+	C <-chan T // 解码后的事件，Unsubscribe 后关闭
+
+	bus   *EventBus
+	topic string
+	ch    EventChan
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Unsubscribe 取消订阅并关闭 C，未读取的事件被丢弃
+func (s *Subscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.bus.Unsubscribe(s.topic, s.ch)
+	})
+}
+
+// PublishContext 编码 v 并同步发布到 topic，见 EventBus.PublishContext
+func (b *Bus[T]) PublishContext(ctx context.Context, topic string, v T) error {
+	data, err := b.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.bus.PublishContext(ctx, topic, Event{Payload: data})
+}
+
+// Subscribe 订阅 topic，返回解码后的事件通道，opts 作用于底层的 EventBus 订阅
+func (b *Bus[T]) Subscribe(topic string, opts ...SubscribeOption) *Subscription[T] {
+	ch := b.bus.Subscribe(topic, opts...)
+	out := make(chan T)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for event := range ch {
+			v, err := b.decode(event.Payload)
+			if err != nil {
+				if b.onError != nil {
+					b.onError(topic, err)
+				}
+				continue
+			}
+			select {
+			case out <- v:
+			case <-done:
+			}
+		}
+	}()
+	return &Subscription[T]{C: out, bus: b.bus, topic: topic, ch: ch, done: done}
+}
+
+// Handle 订阅 topic，并对每个解码后的事件依次调用 fn
+func (b *Bus[T]) Handle(topic string, fn func(T), opts ...SubscribeOption) *Subscription[T] {
+	sub := b.Subscribe(topic, opts...)
+	go func() {
+		for v := range sub.C {
+			fn(v)
+		}
+	}()
+	return sub
+}
+
+// decode 解码载荷，T 为指针类型时先分配其指向的值
+func (b *Bus[T]) decode(data []byte) (T, error) {
+	var v T
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Pointer {
+		v = reflect.New(t.Elem()).Interface().(T)
+		return v, b.codec.Unmarshal(data, v)
+	}
+	return v, b.codec.Unmarshal(data, &v)
+}
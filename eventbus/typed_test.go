@@ -0,0 +1,50 @@
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	Id    string
+	Items []string
+}
+
+func TestBus(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		t.Run(name, func(t *testing.T) {
+			bus := NewBus[order](NewEventBus(), codec)
+			sub := bus.Subscribe("orders")
+			require.NoError(t, bus.Publish("orders", order{Id: "1", Items: []string{"a"}}))
+			require.Equal(t, order{Id: "1", Items: []string{"a"}}, <-sub.C)
+
+			sub.Unsubscribe()
+			_, ok := <-sub.C
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestBus_Proto(t *testing.T) {
+	bus := NewBus[*wrapperspb.StringValue](NewEventBus(), ProtoCodec)
+	got := make(chan string, 1)
+	bus.Handle("names", func(v *wrapperspb.StringValue) { got <- v.GetValue() })
+	require.NoError(t, bus.Publish("names", wrapperspb.String("alice")))
+	require.Equal(t, "alice", <-got)
+
+	_, err := ProtoCodec.Marshal(order{})
+	require.ErrorIs(t, err, ErrNotProtoMessage)
+}
+
+func TestBus_DecodeError(t *testing.T) {
+	eventBus := NewEventBus()
+	errs := make(chan error, 1)
+	bus := NewBus[order](eventBus, nil).OnError(func(topic string, err error) { errs <- err })
+	sub := bus.Subscribe("orders")
+	defer sub.Unsubscribe()
+
+	eventBus.Publish("orders", Event{Payload: []byte("not json")})
+	require.Error(t, <-errs)
+}